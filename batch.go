@@ -0,0 +1,66 @@
+package envelope
+
+import (
+	"google.golang.org/protobuf/proto"
+)
+
+// SerializeBatch serializes a batch of values into a single byte slice safe for storage.
+//
+// Each value is serialized into its own envelope using Serialize and the options are
+// applied to every envelope. The order of the values is preserved. When any values fail
+// to serialize, an ErrBatch error is returned that contains an error for each of them.
+func (r *registry) SerializeBatch(vs []any, opts ...SerializeOption) ([]byte, error) {
+	batch := &EnvelopeBatchMsg{
+		Envelopes: make([][]byte, len(vs)),
+	}
+
+	var errs ErrBatch
+	for i, v := range vs {
+		env, err := r.Serialize(v, opts...)
+		if err != nil {
+			errs = append(errs, ErrBatchItem{Index: i, Err: err})
+			continue
+		}
+		batch.Envelopes[i] = env.Bytes()
+	}
+	if len(errs) != 0 {
+		return nil, errs
+	}
+
+	data, err := proto.Marshal(batch)
+	if err != nil {
+		return nil, serdeError(OpSerialize, StageEnvelope, "", err)
+	}
+
+	return data, nil
+}
+
+// DeserializeBatch deserializes a byte slice created by SerializeBatch into envelopes.
+//
+// The envelopes are returned in the order that the values were serialized. When any
+// envelopes fail to deserialize, the envelopes that did not fail are returned with nil
+// in the place of each failure, along with an ErrBatch error that contains an error
+// for each of the failures.
+func (r *registry) DeserializeBatch(data []byte) ([]Envelope, error) {
+	batch := new(EnvelopeBatchMsg)
+	if err := proto.Unmarshal(data, batch); err != nil {
+		return nil, serdeError(OpDeserialize, StageEnvelope, "", err)
+	}
+
+	envs := make([]Envelope, len(batch.GetEnvelopes()))
+
+	var errs ErrBatch
+	for i, data := range batch.GetEnvelopes() {
+		env, err := r.Deserialize(data)
+		if err != nil {
+			errs = append(errs, ErrBatchItem{Index: i, Err: err})
+			continue
+		}
+		envs[i] = env
+	}
+	if len(errs) != 0 {
+		return envs, errs
+	}
+
+	return envs, nil
+}
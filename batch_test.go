@@ -0,0 +1,95 @@
+package envelope_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stackus/envelope"
+)
+
+func TestRegistry_SerializeBatch(t *testing.T) {
+	tests := map[string]struct {
+		vs          []any
+		wantIndices []int
+	}{
+		"success": {
+			vs: []any{&Test{Test: "one"}, &KeyedTest{Test: "two"}, &Test{Test: "three"}},
+		},
+		"empty": {
+			vs: []any{},
+		},
+		"unregistered": {
+			vs:          []any{&Test{Test: "one"}, &PrefixedTest{}, &KeyedTest{Test: "two"}, &PrefixedTest{}},
+			wantIndices: []int{1, 3},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := envelope.NewRegistry()
+			_ = r.Register(&Test{}, &KeyedTest{})
+
+			data, err := r.SerializeBatch(tt.vs)
+			if tt.wantIndices != nil {
+				var batchErr envelope.ErrBatch
+				if !errors.As(err, &batchErr) {
+					t.Fatalf("Registry.SerializeBatch() error = %v, want %T", err, batchErr)
+				}
+				if len(batchErr) != len(tt.wantIndices) {
+					t.Fatalf("Registry.SerializeBatch() errors = %v, want indices %v", batchErr, tt.wantIndices)
+				}
+				for i, item := range batchErr {
+					if item.Index != tt.wantIndices[i] {
+						t.Errorf("Registry.SerializeBatch() error index = %d, want %d", item.Index, tt.wantIndices[i])
+					}
+				}
+				var unregistered envelope.ErrUnregisteredKey
+				if !errors.As(err, &unregistered) {
+					t.Errorf("Registry.SerializeBatch() error = %v, want it to wrap %T", err, unregistered)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Registry.SerializeBatch() error = %v", err)
+			}
+
+			envs, err := r.DeserializeBatch(data)
+			if err != nil {
+				t.Fatalf("Registry.DeserializeBatch() error = %v", err)
+			}
+			if len(envs) != len(tt.vs) {
+				t.Fatalf("Registry.DeserializeBatch() = %d envelopes, want %d", len(envs), len(tt.vs))
+			}
+			for i, env := range envs {
+				if got, want := env.Payload().(TestType).String(), tt.vs[i].(TestType).String(); got != want {
+					t.Errorf("Registry.DeserializeBatch()[%d] = %v, want %v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRegistry_DeserializeBatch(t *testing.T) {
+	producer := envelope.NewRegistry()
+	_ = producer.Register(&Test{}, &KeyedTest{})
+	consumer := envelope.NewRegistry()
+	_ = consumer.Register(&Test{})
+
+	data, err := producer.SerializeBatch([]any{&Test{Test: "one"}, &KeyedTest{Test: "two"}, &Test{Test: "three"}})
+	if err != nil {
+		t.Fatalf("Registry.SerializeBatch() error = %v", err)
+	}
+
+	envs, err := consumer.DeserializeBatch(data)
+	var batchErr envelope.ErrBatch
+	if !errors.As(err, &batchErr) || len(batchErr) != 1 || batchErr[0].Index != 1 {
+		t.Fatalf("Registry.DeserializeBatch() error = %v, want an error for index 1", err)
+	}
+	if len(envs) != 3 || envs[0] == nil || envs[1] != nil || envs[2] == nil {
+		t.Errorf("Registry.DeserializeBatch() = %v, want a nil envelope at index 1", envs)
+	}
+
+	if _, err = consumer.DeserializeBatch([]byte("not a batch")); err == nil {
+		t.Errorf("Registry.DeserializeBatch() expected an error for invalid data")
+	}
+}
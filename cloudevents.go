@@ -0,0 +1,338 @@
+package envelope
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// CloudEventsSerde is an envelope Serde implementation for CloudEvents 1.0
+//
+// Envelopes are serialized as CloudEvents in the JSON structured content mode. The
+// envelope key becomes the event type and the payload becomes the event data; JSON
+// payloads are embedded as JSON while any other payloads are base64 encoded.
+//
+// The "id", "source", "time", "subject", and "dataschema" metadata become the matching
+// event attributes while the remaining metadata become extension attributes. An id is
+// generated and the Source is used when they are missing from the metadata, and an
+// ErrInvalidCloudEvent error is returned when either would be empty; when
+// envelopes are also signed both should be provided as metadata, otherwise the generated
+// attributes will appear in the metadata of the deserialized envelope and fail verification.
+//
+// Use ToBinary and FromBinary to convert envelopes to and from the binary content mode.
+type CloudEventsSerde struct {
+	Source string // Source is used when the envelope metadata does not include a source
+}
+
+const cloudEventsSpecVersion = "1.0"
+
+// the envelope fields that do not have a matching event attribute are stored as extensions
+const (
+	ceExtVersion        = "envversion"
+	ceExtContentType    = "envcontenttype"
+	ceExtCompression    = "envcompression"
+	ceExtKeyID          = "envkeyid"
+	ceExtSignature      = "envsignature"
+	ceExtSignatureKeyID = "envsignaturekeyid"
+	ceExtSubject        = "envsubject"
+)
+
+var ceReservedAttributes = []string{"specversion", "type", "datacontenttype", "data", "data_base64",
+	ceExtVersion, ceExtContentType, ceExtCompression, ceExtKeyID, ceExtSignature, ceExtSignatureKeyID, ceExtSubject}
+
+func (s CloudEventsSerde) ContentType() string {
+	return "application/cloudevents+json"
+}
+
+func (s CloudEventsSerde) Serialize(v any) ([]byte, error) {
+	msg := v.(*EnvelopeMsg)
+
+	event := map[string]any{
+		"specversion": cloudEventsSpecVersion,
+		"type":        msg.GetKey(),
+		"source":      s.Source,
+	}
+
+	for k, value := range msg.GetMetadata() {
+		if isReservedAttribute(k) {
+			return nil, ErrReservedMetadata(k)
+		}
+		event[k] = value
+	}
+
+	if _, exists := event["id"]; !exists {
+		id, err := newEventID()
+		if err != nil {
+			return nil, err
+		}
+		event["id"] = id
+	}
+
+	// both are required to be non-empty
+	for _, attribute := range []string{"id", "source"} {
+		if event[attribute] == "" {
+			return nil, ErrInvalidCloudEvent(attribute)
+		}
+	}
+
+	if msg.Version != nil {
+		event[ceExtVersion] = msg.GetVersion()
+	}
+	if msg.Compression != nil {
+		event[ceExtCompression] = msg.GetCompression()
+	}
+	if msg.KeyId != nil {
+		event[ceExtKeyID] = msg.GetKeyId()
+	}
+	if msg.Subject != nil {
+		event[ceExtSubject] = msg.GetSubject()
+	}
+	if msg.SignatureKeyId != nil {
+		event[ceExtSignature] = base64.StdEncoding.EncodeToString(msg.GetSignature())
+		event[ceExtSignatureKeyID] = msg.GetSignatureKeyId()
+	}
+
+	contentType := msg.GetContentType()
+	switch {
+	case isOpaque(msg):
+		if msg.ContentType != nil {
+			event[ceExtContentType] = contentType
+		}
+		event["data_base64"] = base64.StdEncoding.EncodeToString(msg.GetPayload())
+	case isEmbeddable(msg):
+		event["datacontenttype"] = contentType
+		event["data"] = json.RawMessage(msg.GetPayload())
+	default:
+		if msg.ContentType != nil {
+			event["datacontenttype"] = contentType
+		}
+		event["data_base64"] = base64.StdEncoding.EncodeToString(msg.GetPayload())
+	}
+
+	return marshalJSON(event)
+}
+
+func (s CloudEventsSerde) Deserialize(data []byte, v any) error {
+	msg := v.(*EnvelopeMsg)
+
+	var event map[string]json.RawMessage
+	if err := json.Unmarshal(data, &event); err != nil {
+		return err
+	}
+
+	var specVersion string
+	if err := json.Unmarshal(event["specversion"], &specVersion); err != nil || specVersion != cloudEventsSpecVersion {
+		return ErrInvalidCloudEvent("specversion")
+	}
+
+	attributes := make(map[string]string, len(event))
+	for k, raw := range event {
+		if k == "data" || k == "data_base64" {
+			continue
+		}
+		value, err := ceAttributeString(raw)
+		if err != nil {
+			return ErrInvalidCloudEvent(k)
+		}
+		attributes[k] = value
+	}
+
+	for _, attribute := range []string{"id", "source"} {
+		if attributes[attribute] == "" {
+			return ErrInvalidCloudEvent(attribute)
+		}
+	}
+
+	key, exists := attributes["type"]
+	if !exists {
+		return ErrInvalidCloudEvent("type")
+	}
+	msg.Key = &key
+
+	if version, exists := attributes[ceExtVersion]; exists {
+		n, err := strconv.ParseUint(version, 10, 32)
+		if err != nil {
+			return ErrInvalidCloudEvent(ceExtVersion)
+		}
+		v32 := uint32(n)
+		msg.Version = &v32
+	}
+	if contentType, exists := attributes[ceExtContentType]; exists {
+		msg.ContentType = &contentType
+	} else if contentType, exists = attributes["datacontenttype"]; exists {
+		msg.ContentType = &contentType
+	}
+	if compression, exists := attributes[ceExtCompression]; exists {
+		msg.Compression = &compression
+	}
+	if keyID, exists := attributes[ceExtKeyID]; exists {
+		msg.KeyId = &keyID
+	}
+	if subject, exists := attributes[ceExtSubject]; exists {
+		msg.Subject = &subject
+	}
+	if signatureKeyID, exists := attributes[ceExtSignatureKeyID]; exists {
+		signature, err := base64.StdEncoding.DecodeString(attributes[ceExtSignature])
+		if err != nil {
+			return ErrInvalidCloudEvent(ceExtSignature)
+		}
+		msg.Signature = signature
+		msg.SignatureKeyId = &signatureKeyID
+	}
+
+	for k, value := range attributes {
+		if isReservedAttribute(k) {
+			continue
+		}
+		if msg.Metadata == nil {
+			msg.Metadata = make(map[string]string)
+		}
+		msg.Metadata[k] = value
+	}
+
+	switch {
+	case event["data_base64"] != nil:
+		var encoded string
+		if err := json.Unmarshal(event["data_base64"], &encoded); err != nil {
+			return ErrInvalidCloudEvent("data_base64")
+		}
+		payload, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return ErrInvalidCloudEvent("data_base64")
+		}
+		msg.Payload = payload
+	case event["data"] != nil:
+		payload := []byte(event["data"])
+		// non-JSON data is carried as a JSON string
+		if !isJSONContentType(msg.GetContentType()) {
+			var str string
+			if err := json.Unmarshal(payload, &str); err == nil {
+				payload = []byte(str)
+			}
+		}
+		msg.Payload = payload
+	}
+
+	return nil
+}
+
+// ToBinary converts an envelope serialized by the CloudEventsSerde into the CloudEvents
+// binary content mode.
+//
+// The event attributes are returned as headers prefixed with "ce-", and the event data
+// is returned as the body with its content type in the "content-type" header.
+func (s CloudEventsSerde) ToBinary(data []byte) (map[string]string, []byte, error) {
+	var event map[string]json.RawMessage
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, nil, err
+	}
+
+	headers := make(map[string]string, len(event))
+	for k, raw := range event {
+		if k == "data" || k == "data_base64" {
+			continue
+		}
+		value, err := ceAttributeString(raw)
+		if err != nil {
+			return nil, nil, ErrInvalidCloudEvent(k)
+		}
+		if k == "datacontenttype" {
+			headers["content-type"] = value
+			continue
+		}
+		headers["ce-"+k] = value
+	}
+
+	var body []byte
+	switch {
+	case event["data_base64"] != nil:
+		var encoded string
+		if err := json.Unmarshal(event["data_base64"], &encoded); err != nil {
+			return nil, nil, ErrInvalidCloudEvent("data_base64")
+		}
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+			return nil, nil, ErrInvalidCloudEvent("data_base64")
+		}
+	case event["data"] != nil:
+		body = event["data"]
+	}
+
+	return headers, body, nil
+}
+
+// FromBinary converts a CloudEvent in the binary content mode into the structured content
+// mode so that it may be deserialized by a registry using the CloudEventsSerde.
+//
+// Header names are matched without regard to case.
+func (s CloudEventsSerde) FromBinary(headers map[string]string, body []byte) ([]byte, error) {
+	event := make(map[string]any, len(headers)+1)
+	for k, value := range headers {
+		k = strings.ToLower(k)
+		switch {
+		case k == "content-type":
+			event["datacontenttype"] = value
+		case strings.HasPrefix(k, "ce-"):
+			event[strings.TrimPrefix(k, "ce-")] = value
+		}
+	}
+
+	if contentType, _ := event["datacontenttype"].(string); isJSONContentType(contentType) && json.Valid(body) {
+		event["data"] = json.RawMessage(body)
+	} else if body != nil {
+		event["data_base64"] = base64.StdEncoding.EncodeToString(body)
+	}
+
+	return json.Marshal(event)
+}
+
+// ceAttributeString returns the string form of an attribute value
+func ceAttributeString(raw json.RawMessage) (string, error) {
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", err
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return string(raw), nil
+	default:
+		return "", ErrInvalidCloudEvent("")
+	}
+}
+
+func isReservedAttribute(k string) bool {
+	for _, reserved := range ceReservedAttributes {
+		if k == reserved {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func newEventID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(id), nil
+}
@@ -0,0 +1,227 @@
+package envelope_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stackus/envelope"
+)
+
+func TestCloudEventsSerde(t *testing.T) {
+	tests := map[string]struct {
+		options  []envelope.RegistryOption
+		metadata map[string]string
+		wantData bool
+		wantErr  error
+	}{
+		"success": {
+			metadata: map[string]string{"correlationid": "abc"},
+			wantData: true,
+		},
+		"attributes": {
+			metadata: map[string]string{"id": "1", "source": "/users", "time": "2024-01-01T00:00:00Z"},
+			wantData: true,
+		},
+		"compressed": {
+			options:  []envelope.RegistryOption{envelope.WithCompression(envelope.GzipCompressor{}, 0)},
+			wantData: false,
+		},
+		"signed": {
+			options: []envelope.RegistryOption{envelope.WithSigning(envelope.HMACSigner{
+				KeyID: "one",
+				Keys:  map[string][]byte{"one": []byte("secret")},
+			})},
+			metadata: map[string]string{"id": "1", "source": "/users"},
+			wantData: true,
+		},
+		"reserved metadata": {
+			metadata: map[string]string{"type": "user"},
+			wantErr:  envelope.ErrReservedMetadata("type"),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			opts := append(tt.options, envelope.WithEnvelopeSerde(envelope.CloudEventsSerde{Source: "/tests"}))
+			r := envelope.NewRegistry(opts...)
+			_ = r.Register(&Test{})
+
+			env, err := r.Serialize(&Test{Test: "testing"}, envelope.WithMetadata(tt.metadata))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Registry.Serialize() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Registry.Serialize() error = %v", err)
+			}
+
+			var event map[string]any
+			if err = json.Unmarshal(env.Bytes(), &event); err != nil {
+				t.Fatalf("Registry.Serialize() did not produce JSON: %v", err)
+			}
+			if event["specversion"] != "1.0" || event["type"] != "envelope_test.Test" || event["id"] == nil || event["source"] == nil {
+				t.Errorf("Registry.Serialize() = %v, missing required attributes", event)
+			}
+			if data, ok := event["data"].(map[string]any); ok != tt.wantData || (ok && data["Test"] != "testing") {
+				t.Errorf("Registry.Serialize() data = %v, want embedded %v", event["data"], tt.wantData)
+			}
+
+			received, err := r.Deserialize(env.Bytes())
+			if err != nil {
+				t.Fatalf("Registry.Deserialize() error = %v", err)
+			}
+			if got := received.Payload().(*Test).Test; got != "testing" {
+				t.Errorf("Registry.Deserialize() = %v, want %v", got, "testing")
+			}
+			for k, v := range tt.metadata {
+				if got := received.Metadata()[k]; got != v {
+					t.Errorf("Registry.Deserialize() metadata[%q] = %q, want %q", k, got, v)
+				}
+			}
+		})
+	}
+}
+
+func TestCloudEventsSerde_Binary(t *testing.T) {
+	serde := envelope.CloudEventsSerde{Source: "/tests"}
+	r := envelope.NewRegistry(envelope.WithEnvelopeSerde(serde))
+	_ = r.Register(&Test{})
+
+	env, err := r.Serialize(&Test{Test: "testing"}, envelope.WithMetadata(map[string]string{"tenantid": "123"}))
+	if err != nil {
+		t.Fatalf("Registry.Serialize() error = %v", err)
+	}
+
+	headers, body, err := serde.ToBinary(env.Bytes())
+	if err != nil {
+		t.Fatalf("CloudEventsSerde.ToBinary() error = %v", err)
+	}
+	if headers["ce-type"] != "envelope_test.Test" || headers["ce-tenantid"] != "123" || headers["content-type"] != "application/json" {
+		t.Errorf("CloudEventsSerde.ToBinary() headers = %v", headers)
+	}
+	if string(body) != `{"Test":"testing"}` {
+		t.Errorf("CloudEventsSerde.ToBinary() body = %s", body)
+	}
+
+	data, err := serde.FromBinary(headers, body)
+	if err != nil {
+		t.Fatalf("CloudEventsSerde.FromBinary() error = %v", err)
+	}
+	received, err := r.Deserialize(data)
+	if err != nil {
+		t.Fatalf("Registry.Deserialize() error = %v", err)
+	}
+	if got := received.Payload().(*Test).Test; got != "testing" {
+		t.Errorf("Registry.Deserialize() = %v, want %v", got, "testing")
+	}
+	if got := received.Metadata()["tenantid"]; got != "123" {
+		t.Errorf("Registry.Deserialize() metadata = %v", received.Metadata())
+	}
+}
+
+func TestCloudEventsSerde_Foreign(t *testing.T) {
+	r := envelope.NewRegistry(envelope.WithEnvelopeSerde(envelope.CloudEventsSerde{}))
+	_ = r.Register(&KeyedTest{})
+
+	tests := map[string]struct {
+		data    string
+		wantErr bool
+	}{
+		"structured": {
+			data: `{"specversion":"1.0","id":"1","source":"/other","type":"test","datacontenttype":"application/json","data":{"Test":"testing"}}`,
+		},
+		"base64": {
+			data: `{"specversion":"1.0","id":"1","source":"/other","type":"test","data_base64":"eyJUZXN0IjoidGVzdGluZyJ9"}`,
+		},
+		"wrong version": {
+			data:    `{"specversion":"0.3","id":"1","source":"/other","type":"test"}`,
+			wantErr: true,
+		},
+		"missing type": {
+			data:    `{"specversion":"1.0","id":"1","source":"/other"}`,
+			wantErr: true,
+		},
+		"missing id": {
+			data:    `{"specversion":"1.0","source":"/other","type":"test","data_base64":"eyJUZXN0IjoidGVzdGluZyJ9"}`,
+			wantErr: true,
+		},
+		"missing source": {
+			data:    `{"specversion":"1.0","id":"1","type":"test","data_base64":"eyJUZXN0IjoidGVzdGluZyJ9"}`,
+			wantErr: true,
+		},
+		"empty source": {
+			data:    `{"specversion":"1.0","id":"1","source":"","type":"test","data_base64":"eyJUZXN0IjoidGVzdGluZyJ9"}`,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			received, err := r.Deserialize([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Registry.Deserialize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := received.Payload().(*KeyedTest).Test; got != "testing" {
+				t.Errorf("Registry.Deserialize() = %v, want %v", got, "testing")
+			}
+			if got := received.Metadata()["source"]; got != "/other" {
+				t.Errorf("Registry.Deserialize() source = %v, want %v", got, "/other")
+			}
+		})
+	}
+}
+
+func TestCloudEventsSerde_RequiredAttributes(t *testing.T) {
+	tests := map[string]struct {
+		source   string
+		metadata map[string]string
+		wantErr  error
+	}{
+		"source": {
+			source: "/tests",
+		},
+		"metadata source": {
+			metadata: map[string]string{"source": "/users"},
+		},
+		"missing source": {
+			wantErr: envelope.ErrInvalidCloudEvent("source"),
+		},
+		"empty metadata source": {
+			source:   "/tests",
+			metadata: map[string]string{"source": ""},
+			wantErr:  envelope.ErrInvalidCloudEvent("source"),
+		},
+		"empty metadata id": {
+			source:   "/tests",
+			metadata: map[string]string{"id": ""},
+			wantErr:  envelope.ErrInvalidCloudEvent("id"),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := envelope.NewRegistry(envelope.WithEnvelopeSerde(envelope.CloudEventsSerde{Source: tt.source}))
+			_ = r.Register(&Test{})
+
+			env, err := r.Serialize(&Test{Test: "testing"}, envelope.WithMetadata(tt.metadata))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Registry.Serialize() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Registry.Serialize() error = %v", err)
+			}
+			if _, err = r.Deserialize(env.Bytes()); err != nil {
+				t.Errorf("Registry.Deserialize() error = %v", err)
+			}
+		})
+	}
+}
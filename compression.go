@@ -0,0 +1,253 @@
+package envelope
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+)
+
+// DefaultMaxDecompressedSize is the largest payload that will be decompressed by a
+// registry that was not given a maximum payload size with WithMaxPayloadSize.
+const DefaultMaxDecompressedSize = 64 << 20
+
+// Compressor compresses and decompresses payloads.
+//
+// The name of the compressor is sealed into each envelope with a compressed
+// payload and is used to find the compressor when the envelope is deserialized.
+//
+// Compressors may also implement an optional DecompressLimit(data []byte, limit int) ([]byte, error)
+// method that stops decompressing, and returns an ErrPayloadTooLarge error, once the payload
+// is larger than limit bytes. Other compressors decompress the entire payload before its
+// size is checked.
+type Compressor interface {
+	Name() string
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+// GzipCompressor is a Compressor implementation for gzip
+//
+// It uses the compress/gzip package to compress and decompress data.
+type GzipCompressor struct {
+	Level int // Level is the gzip compression level; zero uses gzip.DefaultCompression
+}
+
+func (c GzipCompressor) Name() string {
+	return "gzip"
+}
+
+func (c GzipCompressor) Compress(data []byte) ([]byte, error) {
+	level := c.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(data); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (c GzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+func (c GzipCompressor) DecompressLimit(data []byte, limit int) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	// read one byte past the limit to find out if the payload is too large
+	decompressed, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(decompressed) > limit {
+		return nil, ErrPayloadTooLarge{Size: len(decompressed), Max: limit}
+	}
+
+	return decompressed, nil
+}
+
+// ZstdCompressor is a Compressor implementation for Zstandard
+//
+// It uses the github.com/klauspost/compress/zstd package to compress and decompress data.
+type ZstdCompressor struct{}
+
+var zstdCodec = sync.OnceValues(func() (*zstd.Encoder, *zstd.Decoder) {
+	// neither will return an error without any options
+	enc, _ := zstd.NewWriter(nil)
+	dec, _ := zstd.NewReader(nil)
+	return enc, dec
+})
+
+// zstdLimitedDecoders holds a decoder for each limit that payloads are decompressed with
+var zstdLimitedDecoders sync.Map
+
+func zstdLimitedDecoder(limit int) (*zstd.Decoder, error) {
+	if dec, ok := zstdLimitedDecoders.Load(limit); ok {
+		return dec.(*zstd.Decoder), nil
+	}
+
+	dec, err := zstd.NewReader(nil,
+		zstd.WithDecoderMaxMemory(uint64(max(limit, 1))),
+		zstd.WithDecoderMaxWindow(uint64(max(limit, zstd.MinWindowSize))),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if existing, loaded := zstdLimitedDecoders.LoadOrStore(limit, dec); loaded {
+		dec.Close()
+		return existing.(*zstd.Decoder), nil
+	}
+	return dec, nil
+}
+
+func (c ZstdCompressor) Name() string {
+	return "zstd"
+}
+
+func (c ZstdCompressor) Compress(data []byte) ([]byte, error) {
+	enc, _ := zstdCodec()
+	return enc.EncodeAll(data, nil), nil
+}
+
+func (c ZstdCompressor) Decompress(data []byte) ([]byte, error) {
+	_, dec := zstdCodec()
+	return dec.DecodeAll(data, nil)
+}
+
+func (c ZstdCompressor) DecompressLimit(data []byte, limit int) ([]byte, error) {
+	dec, err := zstdLimitedDecoder(limit)
+	if err != nil {
+		return nil, err
+	}
+
+	decompressed, err := dec.DecodeAll(data, nil)
+	if errors.Is(err, zstd.ErrDecoderSizeExceeded) || errors.Is(err, zstd.ErrWindowSizeExceeded) {
+		return nil, ErrPayloadTooLarge{Size: limit + 1, Max: limit}
+	}
+	return decompressed, err
+}
+
+// SnappyCompressor is a Compressor implementation for Snappy
+//
+// It uses the github.com/klauspost/compress/s2 package to compress and decompress
+// data using the Snappy block format.
+type SnappyCompressor struct{}
+
+func (c SnappyCompressor) Name() string {
+	return "snappy"
+}
+
+func (c SnappyCompressor) Compress(data []byte) ([]byte, error) {
+	return s2.EncodeSnappy(nil, data), nil
+}
+
+func (c SnappyCompressor) Decompress(data []byte) ([]byte, error) {
+	return s2.Decode(nil, data)
+}
+
+func (c SnappyCompressor) DecompressLimit(data []byte, limit int) ([]byte, error) {
+	// the decoded length is read from the header before anything is allocated
+	size, err := s2.DecodedLen(data)
+	if err != nil {
+		return nil, err
+	}
+	if size > limit {
+		return nil, ErrPayloadTooLarge{Size: size, Max: limit}
+	}
+
+	return s2.Decode(nil, data)
+}
+
+func (r *registry) compress(data []byte) ([]byte, string, error) {
+	if r.compressor == nil || len(data) < r.compressionThreshold {
+		return data, "", nil
+	}
+
+	compressed, err := r.compressor.Compress(data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return compressed, r.compressor.Name(), nil
+}
+
+func (r *registry) decompress(key, name string, data []byte) ([]byte, error) {
+	if name == "" {
+		return data, nil
+	}
+
+	if r.compressor != nil && r.compressor.Name() == name {
+		return r.decompressWith(key, r.compressor, data)
+	}
+
+	for _, c := range r.compressors {
+		if c.Name() == name {
+			return r.decompressWith(key, c, data)
+		}
+	}
+
+	return nil, ErrUnknownCompression{Key: key, Compression: name}
+}
+
+func (r *registry) decompressWith(key string, c Compressor, data []byte) ([]byte, error) {
+	limit := r.decompressLimit()
+
+	var decompressed []byte
+	var err error
+	if limiter, ok := c.(interface {
+		DecompressLimit(data []byte, limit int) ([]byte, error)
+	}); ok {
+		decompressed, err = limiter.DecompressLimit(data, limit)
+	} else {
+		decompressed, err = c.Decompress(data)
+	}
+
+	var tooLarge ErrPayloadTooLarge
+	if errors.As(err, &tooLarge) {
+		tooLarge.Key = key
+		return nil, tooLarge
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(decompressed) > limit {
+		return nil, ErrPayloadTooLarge{Key: key, Size: len(decompressed), Max: limit}
+	}
+
+	return decompressed, nil
+}
+
+// decompressLimit returns the maximum size of a decompressed payload; compressed payloads
+// are always limited because a small envelope may decompress into a huge payload
+func (r *registry) decompressLimit() int {
+	if r.maxPayloadSize > 0 {
+		return r.maxPayloadSize
+	}
+
+	return DefaultMaxDecompressedSize
+}
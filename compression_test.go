@@ -0,0 +1,155 @@
+package envelope_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stackus/envelope"
+)
+
+type reverseCompressor struct{}
+
+func (reverseCompressor) Name() string {
+	return "reverse"
+}
+
+func (reverseCompressor) Compress(data []byte) ([]byte, error) {
+	out := make([]byte, len(data))
+	for i, b := range data {
+		out[len(data)-1-i] = b
+	}
+	return out, nil
+}
+
+func (c reverseCompressor) Decompress(data []byte) ([]byte, error) {
+	return c.Compress(data)
+}
+
+var largeTest = &Test{
+	Test: strings.Repeat("the quick brown fox jumps over the lazy dog. ", 1000),
+}
+
+func TestRegistry_Compression(t *testing.T) {
+	tests := map[string]struct {
+		producer    []envelope.RegistryOption
+		consumer    []envelope.RegistryOption
+		wantSmaller bool
+		wantErr     bool
+	}{
+		"none": {},
+		"gzip": {
+			producer:    []envelope.RegistryOption{envelope.WithCompression(envelope.GzipCompressor{}, 1024)},
+			wantSmaller: true,
+		},
+		"gzip level": {
+			producer:    []envelope.RegistryOption{envelope.WithCompression(envelope.GzipCompressor{Level: 9}, 1024)},
+			wantSmaller: true,
+		},
+		"zstd": {
+			producer:    []envelope.RegistryOption{envelope.WithCompression(envelope.ZstdCompressor{}, 1024)},
+			wantSmaller: true,
+		},
+		"snappy": {
+			producer:    []envelope.RegistryOption{envelope.WithCompression(envelope.SnappyCompressor{}, 1024)},
+			wantSmaller: true,
+		},
+		"below threshold": {
+			producer: []envelope.RegistryOption{envelope.WithCompression(envelope.GzipCompressor{}, 1<<20)},
+		},
+		"added compressor": {
+			producer: []envelope.RegistryOption{envelope.WithCompression(reverseCompressor{}, 0)},
+			consumer: []envelope.RegistryOption{envelope.WithCompressors(reverseCompressor{})},
+		},
+		"unknown compressor": {
+			producer: []envelope.RegistryOption{envelope.WithCompression(reverseCompressor{}, 0)},
+			wantErr:  true,
+		},
+	}
+
+	uncompressed := func() int {
+		r := envelope.NewRegistry()
+		_ = r.Register(&Test{})
+		env, _ := r.Serialize(largeTest)
+		return len(env.Bytes())
+	}()
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			producer := envelope.NewRegistry(tt.producer...)
+			_ = producer.Register(&Test{})
+			consumer := envelope.NewRegistry(tt.consumer...)
+			_ = consumer.Register(&Test{})
+
+			env, err := producer.Serialize(largeTest)
+			if err != nil {
+				t.Fatalf("Registry.Serialize() error = %v", err)
+			}
+			if tt.wantSmaller && len(env.Bytes()) >= uncompressed {
+				t.Errorf("Registry.Serialize() size = %d, want less than %d", len(env.Bytes()), uncompressed)
+			}
+
+			received, err := consumer.Deserialize(env.Bytes())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Registry.Deserialize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				var unknown envelope.ErrUnknownCompression
+				if !errors.As(err, &unknown) {
+					t.Errorf("Registry.Deserialize() error = %T, want %T", err, unknown)
+				}
+				return
+			}
+			if got := received.Payload().(*Test).Test; got != largeTest.Test {
+				t.Errorf("Registry.Deserialize() payload was not restored")
+			}
+		})
+	}
+}
+
+var benchmarkCompressors = map[string][]envelope.RegistryOption{
+	"none":   nil,
+	"gzip":   {envelope.WithCompression(envelope.GzipCompressor{}, 1024)},
+	"zstd":   {envelope.WithCompression(envelope.ZstdCompressor{}, 1024)},
+	"snappy": {envelope.WithCompression(envelope.SnappyCompressor{}, 1024)},
+}
+
+func BenchmarkRegistry_Serialize(b *testing.B) {
+	for name, opts := range benchmarkCompressors {
+		b.Run(name, func(b *testing.B) {
+			r := envelope.NewRegistry(opts...)
+			_ = r.Register(&Test{})
+
+			b.SetBytes(int64(len(largeTest.Test)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := r.Serialize(largeTest); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkRegistry_Deserialize(b *testing.B) {
+	for name, opts := range benchmarkCompressors {
+		b.Run(name, func(b *testing.B) {
+			r := envelope.NewRegistry(opts...)
+			_ = r.Register(&Test{})
+			env, err := r.Serialize(largeTest)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.SetBytes(int64(len(largeTest.Test)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err = r.Deserialize(env.Bytes()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"sync"
+)
+
+type (
+	// KeyProvider provides the keys used to encrypt and decrypt payloads.
+	//
+	// Keys must be 16, 24, or 32 bytes long to select AES-128, AES-192, or AES-256.
+	KeyProvider interface {
+		// CurrentKey returns the ID and the key used to encrypt new payloads
+		CurrentKey() (id string, key []byte, err error)
+		// Key returns the key with the given ID
+		Key(id string) ([]byte, error)
+	}
+
+	// MemoryKeyProvider is an in-memory KeyProvider
+	//
+	// The most recently added key is used to encrypt new payloads while any
+	// previously added keys remain available to decrypt older payloads.
+	MemoryKeyProvider struct {
+		current string
+		keys    map[string][]byte
+		mu      sync.RWMutex
+	}
+)
+
+// NewMemoryKeyProvider creates a new in-memory KeyProvider.
+func NewMemoryKeyProvider() *MemoryKeyProvider {
+	return &MemoryKeyProvider{
+		keys: make(map[string][]byte),
+	}
+}
+
+// AddKey adds a key to the provider and makes it the current key.
+func (p *MemoryKeyProvider) AddKey(id string, key []byte) error {
+	if _, err := aes.NewCipher(key); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.keys[id] = key
+	p.current = id
+	return nil
+}
+
+// RemoveKey removes a key from the provider.
+//
+// Payloads encrypted with the key will no longer be decryptable.
+func (p *MemoryKeyProvider) RemoveKey(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.keys, id)
+	if p.current == id {
+		p.current = ""
+	}
+}
+
+func (p *MemoryKeyProvider) CurrentKey() (string, []byte, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.current == "" {
+		return "", nil, ErrKeyNotFound("")
+	}
+
+	return p.current, p.keys[p.current], nil
+}
+
+func (p *MemoryKeyProvider) Key(id string) ([]byte, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	key, exists := p.keys[id]
+	if !exists {
+		return nil, ErrKeyNotFound(id)
+	}
+
+	return key, nil
+}
+
+func (r *registry) encrypt(key string, data []byte) ([]byte, string, error) {
+	if r.keyProvider == nil {
+		return data, "", nil
+	}
+
+	id, secret, err := r.keyProvider.CurrentKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	data, err = seal(secret, data, []byte(key))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return data, id, nil
+}
+
+func (r *registry) decrypt(key, id string, data []byte) ([]byte, error) {
+	if id == "" {
+		return data, nil
+	}
+
+	if r.keyProvider == nil {
+		return nil, ErrNoKeyProvider(key)
+	}
+
+	secret, err := r.keyProvider.Key(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return open(secret, data, []byte(key))
+}
+
+// seal encrypts the data using AES-GCM; the nonce is prepended to the result
+func seal(secret, data, additional []byte) ([]byte, error) {
+	aead, err := newAEAD(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(data)+aead.Overhead())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, data, additional), nil
+}
+
+// open decrypts data that was encrypted by seal
+func open(secret, data, additional []byte) ([]byte, error) {
+	aead, err := newAEAD(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < aead.NonceSize() {
+		return nil, ErrDecryptionFailed(string(additional))
+	}
+
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additional)
+	if err != nil {
+		return nil, ErrDecryptionFailed(string(additional))
+	}
+
+	return plaintext, nil
+}
+
+func newAEAD(secret []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
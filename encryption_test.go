@@ -0,0 +1,140 @@
+package envelope_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stackus/envelope"
+)
+
+var (
+	keyOne = bytes.Repeat([]byte{1}, 32)
+	keyTwo = bytes.Repeat([]byte{2}, 32)
+)
+
+func TestRegistry_Encryption(t *testing.T) {
+	tests := map[string]struct {
+		producer func() envelope.KeyProvider
+		consumer func(producer envelope.KeyProvider) envelope.KeyProvider
+		wantErr  error
+	}{
+		"success": {
+			producer: func() envelope.KeyProvider {
+				kp := envelope.NewMemoryKeyProvider()
+				_ = kp.AddKey("one", keyOne)
+				return kp
+			},
+			consumer: func(producer envelope.KeyProvider) envelope.KeyProvider {
+				return producer
+			},
+		},
+		"rotated": {
+			producer: func() envelope.KeyProvider {
+				kp := envelope.NewMemoryKeyProvider()
+				_ = kp.AddKey("one", keyOne)
+				return kp
+			},
+			consumer: func(producer envelope.KeyProvider) envelope.KeyProvider {
+				_ = producer.(*envelope.MemoryKeyProvider).AddKey("two", keyTwo)
+				return producer
+			},
+		},
+		"removed key": {
+			producer: func() envelope.KeyProvider {
+				kp := envelope.NewMemoryKeyProvider()
+				_ = kp.AddKey("one", keyOne)
+				return kp
+			},
+			consumer: func(producer envelope.KeyProvider) envelope.KeyProvider {
+				kp := producer.(*envelope.MemoryKeyProvider)
+				_ = kp.AddKey("two", keyTwo)
+				kp.RemoveKey("one")
+				return kp
+			},
+			wantErr: envelope.ErrKeyNotFound("one"),
+		},
+		"wrong key": {
+			producer: func() envelope.KeyProvider {
+				kp := envelope.NewMemoryKeyProvider()
+				_ = kp.AddKey("one", keyOne)
+				return kp
+			},
+			consumer: func(envelope.KeyProvider) envelope.KeyProvider {
+				kp := envelope.NewMemoryKeyProvider()
+				_ = kp.AddKey("one", keyTwo)
+				return kp
+			},
+			wantErr: envelope.ErrDecryptionFailed("envelope_test.Test"),
+		},
+		"no key provider": {
+			producer: func() envelope.KeyProvider {
+				kp := envelope.NewMemoryKeyProvider()
+				_ = kp.AddKey("one", keyOne)
+				return kp
+			},
+			consumer: func(envelope.KeyProvider) envelope.KeyProvider {
+				return nil
+			},
+			wantErr: envelope.ErrNoKeyProvider("envelope_test.Test"),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			producerKeys := tt.producer()
+			producer := envelope.NewRegistry(envelope.WithEncryption(producerKeys))
+			_ = producer.Register(&Test{})
+
+			env, err := producer.Serialize(&Test{Test: "top secret"})
+			if err != nil {
+				t.Fatalf("Registry.Serialize() error = %v", err)
+			}
+			if bytes.Contains(env.Bytes(), []byte("top secret")) {
+				t.Errorf("Registry.Serialize() payload was not encrypted")
+			}
+
+			var consumer envelope.Registry
+			if consumerKeys := tt.consumer(producerKeys); consumerKeys != nil {
+				consumer = envelope.NewRegistry(envelope.WithEncryption(consumerKeys))
+			} else {
+				consumer = envelope.NewRegistry()
+			}
+			_ = consumer.Register(&Test{})
+
+			received, err := consumer.Deserialize(env.Bytes())
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Registry.Deserialize() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Registry.Deserialize() error = %v", err)
+			}
+			if got := received.Payload().(*Test).Test; got != "top secret" {
+				t.Errorf("Registry.Deserialize() = %v, want %v", got, "top secret")
+			}
+		})
+	}
+}
+
+func TestMemoryKeyProvider(t *testing.T) {
+	kp := envelope.NewMemoryKeyProvider()
+
+	if _, _, err := kp.CurrentKey(); err == nil {
+		t.Errorf("MemoryKeyProvider.CurrentKey() expected an error with no keys")
+	}
+	if err := kp.AddKey("short", []byte("short")); err == nil {
+		t.Errorf("MemoryKeyProvider.AddKey() expected an error for an invalid key")
+	}
+
+	_ = kp.AddKey("one", keyOne)
+	_ = kp.AddKey("two", keyTwo)
+	if id, key, _ := kp.CurrentKey(); id != "two" || !bytes.Equal(key, keyTwo) {
+		t.Errorf("MemoryKeyProvider.CurrentKey() = %v, want %v", id, "two")
+	}
+	if key, _ := kp.Key("one"); !bytes.Equal(key, keyOne) {
+		t.Errorf("MemoryKeyProvider.Key() = %v, want %v", key, keyOne)
+	}
+}
@@ -9,6 +9,97 @@ type (
 	ErrReregisteredKey             string
 	ErrFactoryReturnsNil           string
 	ErrFactoryDoesNotReturnPointer string
+	ErrRegistryFrozen              string
+	ErrKeyNotFound                 string
+	ErrNoKeyProvider               string
+	ErrDecryptionFailed            string
+	ErrInvalidSignature            string
+	ErrReservedMetadata            string
+	ErrInvalidCloudEvent           string
+	ErrInvalidKey                  string
+	ErrNotProtoMessage             string
+	ErrUnknownField                string
+	ErrTrailingData                int
+	ErrTruncatedRecord             int64
+	ErrMissingKey                  struct{}
+	ErrNotJSONObject               struct{}
+
+	ErrReregisteredUpcaster struct {
+		Key     string
+		Version uint32
+	}
+	ErrMissingUpcaster struct {
+		Key     string
+		Version uint32
+	}
+	ErrUnsupportedVersion struct {
+		Key     string
+		Version uint32
+	}
+	ErrTypeMismatch struct {
+		Key  string
+		Type string
+	}
+	ErrKeyTypeMismatch struct {
+		Key  string
+		Type string
+	}
+	ErrContentTypeMismatch struct {
+		Key         string
+		ContentType string
+	}
+	ErrUnknownCompression struct {
+		Key         string
+		Compression string
+	}
+	ErrSubjectForgotten struct {
+		Key     string
+		Subject string
+	}
+	ErrPayloadTooLarge struct {
+		Key  string
+		Size int // Size is Max+1 when decompression was stopped at the limit
+		Max  int
+	}
+	ErrValidation struct {
+		Key string
+		Err error
+	}
+	ErrBatchItem struct {
+		Index int
+		Err   error
+	}
+	ErrBatch []ErrBatchItem
+
+	// Op is the operation that failed; it may be used with errors.Is to match any
+	// SerdeError returned by that operation
+	Op string
+	// Stage is the stage of an operation that failed; it may be used with errors.Is to
+	// match any SerdeError returned by that stage
+	Stage string
+
+	// SerdeError is returned by the serialize and deserialize operations of a registry
+	// and wraps the error of the stage that failed.
+	SerdeError struct {
+		Op    Op
+		Stage Stage
+		Key   string // Key is empty when the envelope could not be opened
+		Err   error
+	}
+)
+
+const (
+	OpSerialize   Op = "serialize"
+	OpDeserialize Op = "deserialize"
+
+	StageLookup      Stage = "lookup"      // StageLookup finds the type registered for the key
+	StagePayload     Stage = "payload"     // StagePayload serializes or deserializes the payload
+	StageCompression Stage = "compression" // StageCompression compresses or decompresses the payload
+	StageEncryption  Stage = "encryption"  // StageEncryption encrypts or decrypts the payload
+	StageVersion     Stage = "version"     // StageVersion upcasts the payload to the registered version
+	StageValidation  Stage = "validation"  // StageValidation validates the payload
+	StageSignature   Stage = "signature"   // StageSignature signs or verifies the envelope
+	StageEnvelope    Stage = "envelope"    // StageEnvelope serializes or deserializes the envelope
 )
 
 func (e ErrUnregisteredKey) Error() string {
@@ -26,3 +117,160 @@ func (e ErrFactoryReturnsNil) Error() string {
 func (e ErrFactoryDoesNotReturnPointer) Error() string {
 	return fmt.Sprintf("factory for %q did not return a pointer", string(e))
 }
+
+func (e ErrRegistryFrozen) Error() string {
+	return fmt.Sprintf("cannot register %q; the registry is frozen", string(e))
+}
+
+func (e ErrKeyNotFound) Error() string {
+	return fmt.Sprintf("the encryption key %q was not found", string(e))
+}
+
+func (e ErrNoKeyProvider) Error() string {
+	return fmt.Sprintf("the payload for %q is encrypted and no key provider is available", string(e))
+}
+
+func (e ErrDecryptionFailed) Error() string {
+	return fmt.Sprintf("the payload for %q could not be decrypted", string(e))
+}
+
+func (e ErrInvalidSignature) Error() string {
+	return fmt.Sprintf("the signature for %q is invalid", string(e))
+}
+
+func (e ErrReservedMetadata) Error() string {
+	return fmt.Sprintf("the metadata key %q is reserved", string(e))
+}
+
+func (e ErrInvalidCloudEvent) Error() string {
+	return fmt.Sprintf("the cloud event attribute %q is missing or invalid", string(e))
+}
+
+func (e ErrInvalidKey) Error() string {
+	return fmt.Sprintf("the key %q is not valid UTF-8", string(e))
+}
+
+func (e ErrNotProtoMessage) Error() string {
+	return fmt.Sprintf("%s is not a proto.Message", string(e))
+}
+
+func (e ErrUnknownField) Error() string {
+	return fmt.Sprintf("the payload has the unknown field %q", string(e))
+}
+
+func (e ErrTrailingData) Error() string {
+	return fmt.Sprintf("the payload has %d bytes of data after the JSON value", int(e))
+}
+
+func (e ErrMissingKey) Error() string {
+	return "the envelope does not have a key"
+}
+
+func (e ErrNotJSONObject) Error() string {
+	return "the payload is not a JSON object"
+}
+
+func (e ErrTruncatedRecord) Error() string {
+	return fmt.Sprintf("the record at offset %d is truncated", int64(e))
+}
+
+func (e ErrReregisteredUpcaster) Error() string {
+	return fmt.Sprintf("an upcaster has already been registered for %q version %d", e.Key, e.Version)
+}
+
+func (e ErrMissingUpcaster) Error() string {
+	return fmt.Sprintf("no upcaster has been registered for %q version %d", e.Key, e.Version)
+}
+
+func (e ErrUnsupportedVersion) Error() string {
+	return fmt.Sprintf("version %d of %q is newer than the registered type", e.Version, e.Key)
+}
+
+func (e ErrTypeMismatch) Error() string {
+	return fmt.Sprintf("%q does not satisfy %s", e.Key, e.Type)
+}
+
+func (e ErrKeyTypeMismatch) Error() string {
+	return fmt.Sprintf("a %s cannot be serialized as %q; the key is registered for another type", e.Type, e.Key)
+}
+
+func (e ErrContentTypeMismatch) Error() string {
+	return fmt.Sprintf("no serde is available for %q payloads with the content type %q", e.Key, e.ContentType)
+}
+
+func (e ErrUnknownCompression) Error() string {
+	return fmt.Sprintf("no compressor is available for %q payloads compressed with %q", e.Key, e.Compression)
+}
+
+func (e ErrSubjectForgotten) Error() string {
+	return fmt.Sprintf("the payload for %q belongs to the forgotten subject %q", e.Key, e.Subject)
+}
+
+func (e ErrPayloadTooLarge) Error() string {
+	return fmt.Sprintf("the payload for %q is %d bytes; the maximum is %d bytes", e.Key, e.Size, e.Max)
+}
+
+func (e ErrValidation) Error() string {
+	return fmt.Sprintf("the payload for %q is invalid: %v", e.Key, e.Err)
+}
+
+func (e ErrValidation) Unwrap() error {
+	return e.Err
+}
+
+func (e ErrBatchItem) Error() string {
+	return fmt.Sprintf("batch item %d: %v", e.Index, e.Err)
+}
+
+func (e ErrBatchItem) Unwrap() error {
+	return e.Err
+}
+
+func (e ErrBatch) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	return fmt.Sprintf("%d batch items failed; %v", len(e), e[0])
+}
+
+func (e ErrBatch) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+func (e Op) Error() string {
+	return fmt.Sprintf("could not %s", string(e))
+}
+
+func (e Stage) Error() string {
+	return fmt.Sprintf("the %s stage failed", string(e))
+}
+
+func (e *SerdeError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("could not %s at the %s stage: %v", string(e.Op), string(e.Stage), e.Err)
+	}
+	return fmt.Sprintf("could not %s %q at the %s stage: %v", string(e.Op), e.Key, string(e.Stage), e.Err)
+}
+
+func (e *SerdeError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the target is the Op or Stage of the error.
+func (e *SerdeError) Is(target error) bool {
+	switch t := target.(type) {
+	case Op:
+		return t == e.Op
+	case Stage:
+		return t == e.Stage
+	}
+	return false
+}
+
+func serdeError(op Op, stage Stage, key string, err error) error {
+	return &SerdeError{Op: op, Stage: stage, Key: key, Err: err}
+}
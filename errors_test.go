@@ -0,0 +1,126 @@
+package envelope_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stackus/envelope"
+)
+
+func TestSerdeError(t *testing.T) {
+	keys := map[string][]byte{"one": []byte("secret")}
+
+	tests := map[string]struct {
+		opts      []envelope.RegistryOption
+		v         any
+		data      []byte
+		wantOp    envelope.Op
+		wantStage envelope.Stage
+		wantKey   string
+		wantErr   error
+	}{
+		"serialize lookup": {
+			v:         &KeyedTest{},
+			wantOp:    envelope.OpSerialize,
+			wantStage: envelope.StageLookup,
+			wantKey:   "test",
+			wantErr:   envelope.ErrUnregisteredKey("test"),
+		},
+		"serialize payload": {
+			opts:      []envelope.RegistryOption{envelope.WithSerde(brokenSerializer{})},
+			v:         &Test{},
+			wantOp:    envelope.OpSerialize,
+			wantStage: envelope.StagePayload,
+			wantKey:   "envelope_test.Test",
+		},
+		"serialize envelope": {
+			opts:      []envelope.RegistryOption{envelope.WithEnvelopeSerde(brokenSerializer{})},
+			v:         &Test{},
+			wantOp:    envelope.OpSerialize,
+			wantStage: envelope.StageEnvelope,
+			wantKey:   "envelope_test.Test",
+		},
+		"serialize signature": {
+			opts:      []envelope.RegistryOption{envelope.WithSigning(envelope.HMACSigner{KeyID: "two", Keys: keys})},
+			v:         &Test{},
+			wantOp:    envelope.OpSerialize,
+			wantStage: envelope.StageSignature,
+			wantKey:   "envelope_test.Test",
+			wantErr:   envelope.ErrKeyNotFound("two"),
+		},
+		"deserialize envelope": {
+			data:      []byte("garbage"),
+			wantOp:    envelope.OpDeserialize,
+			wantStage: envelope.StageEnvelope,
+		},
+		"deserialize payload": {
+			opts:      []envelope.RegistryOption{envelope.WithSerde(brokenDeserializer{})},
+			v:         &Test{},
+			wantOp:    envelope.OpDeserialize,
+			wantStage: envelope.StagePayload,
+			wantKey:   "envelope_test.Test",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := envelope.NewRegistry(tt.opts...)
+			_ = r.Register(&Test{})
+
+			var err error
+			if tt.data != nil {
+				_, err = r.Deserialize(tt.data)
+			} else {
+				var env envelope.Envelope
+				env, err = r.Serialize(tt.v)
+				if tt.wantOp == envelope.OpDeserialize {
+					if err != nil {
+						t.Fatalf("Registry.Serialize() error = %v", err)
+					}
+					_, err = r.Deserialize(env.Bytes())
+				}
+			}
+
+			var serdeErr *envelope.SerdeError
+			if !errors.As(err, &serdeErr) {
+				t.Fatalf("error = %v, want %T", err, serdeErr)
+			}
+			if serdeErr.Op != tt.wantOp || serdeErr.Stage != tt.wantStage || serdeErr.Key != tt.wantKey {
+				t.Errorf("SerdeError = %v %v %q, want %v %v %q", serdeErr.Op, serdeErr.Stage, serdeErr.Key, tt.wantOp, tt.wantStage, tt.wantKey)
+			}
+			if !errors.Is(err, tt.wantOp) || !errors.Is(err, tt.wantStage) {
+				t.Errorf("errors.Is(%v) does not match %v and %v", err, tt.wantOp, tt.wantStage)
+			}
+			if errors.Is(err, envelope.StageCompression) {
+				t.Errorf("errors.Is(%v) matches %v", err, envelope.StageCompression)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want it to wrap %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSerdeError_Error(t *testing.T) {
+	tests := map[string]struct {
+		err  *envelope.SerdeError
+		want string
+	}{
+		"key": {
+			err:  &envelope.SerdeError{Op: envelope.OpDeserialize, Stage: envelope.StagePayload, Key: "test", Err: errors.New("broken")},
+			want: `could not deserialize "test" at the payload stage: broken`,
+		},
+		"no key": {
+			err:  &envelope.SerdeError{Op: envelope.OpSerialize, Stage: envelope.StageEnvelope, Err: errors.New("broken")},
+			want: `could not serialize at the envelope stage: broken`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.want {
+				t.Errorf("SerdeError.Error() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
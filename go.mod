@@ -2,4 +2,7 @@ module github.com/stackus/envelope
 
 go 1.23
 
-require google.golang.org/protobuf v1.36.4
+require (
+	github.com/klauspost/compress v1.18.0
+	google.golang.org/protobuf v1.36.4
+)
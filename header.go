@@ -0,0 +1,110 @@
+package envelope
+
+import (
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	envelopeKeyField      protowire.Number = 1
+	envelopeMetadataField protowire.Number = 3
+	mapEntryKeyField      protowire.Number = 1
+	mapEntryValueField    protowire.Number = 2
+)
+
+// PeekKey returns the key of an envelope that was serialized with the ProtoSerde.
+//
+// The key is read directly from the protobuf wire format without deserializing the
+// envelope or copying its payload, making it much cheaper than Deserialize when only
+// the key is needed, such as when routing envelopes. The key is not resolved through
+// any aliases and any signature is not verified.
+//
+// An ErrMissingKey error is returned when the envelope does not have a key, and an
+// ErrInvalidKey error is returned when the key is not valid UTF-8.
+func PeekKey(data []byte) (string, error) {
+	key, _, err := peek(data, false)
+	return key, err
+}
+
+// PeekHeader returns the key and metadata of an envelope that was serialized with the ProtoSerde.
+//
+// Like PeekKey, the header is read directly from the protobuf wire format.
+func PeekHeader(data []byte) (string, map[string]string, error) {
+	return peek(data, true)
+}
+
+func peek(data []byte, withMetadata bool) (string, map[string]string, error) {
+	var key []byte
+	var metadata map[string]string
+
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return "", nil, protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		switch {
+		case num == envelopeKeyField && typ == protowire.BytesType:
+			if key, n = protowire.ConsumeBytes(data); n < 0 {
+				return "", nil, protowire.ParseError(n)
+			}
+		case num == envelopeMetadataField && typ == protowire.BytesType && withMetadata:
+			var entry []byte
+			if entry, n = protowire.ConsumeBytes(data); n < 0 {
+				return "", nil, protowire.ParseError(n)
+			}
+			k, v, err := peekMapEntry(entry)
+			if err != nil {
+				return "", nil, err
+			}
+			if metadata == nil {
+				metadata = make(map[string]string)
+			}
+			metadata[k] = v
+		default:
+			// skip the payload and every other field
+			if n = protowire.ConsumeFieldValue(num, typ, data); n < 0 {
+				return "", nil, protowire.ParseError(n)
+			}
+		}
+		data = data[n:]
+	}
+
+	if len(key) == 0 {
+		return "", nil, ErrMissingKey{}
+	}
+	if !utf8.Valid(key) {
+		return "", nil, ErrInvalidKey(key)
+	}
+
+	return string(key), metadata, nil
+}
+
+func peekMapEntry(data []byte) (string, string, error) {
+	var key, value []byte
+
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return "", "", protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		switch {
+		case num == mapEntryKeyField && typ == protowire.BytesType:
+			key, n = protowire.ConsumeBytes(data)
+		case num == mapEntryValueField && typ == protowire.BytesType:
+			value, n = protowire.ConsumeBytes(data)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return "", "", protowire.ParseError(n)
+		}
+		data = data[n:]
+	}
+
+	return string(key), string(value), nil
+}
@@ -0,0 +1,131 @@
+package envelope_test
+
+import (
+	"testing"
+
+	"github.com/stackus/envelope"
+)
+
+func TestPeekHeader(t *testing.T) {
+	r := envelope.NewRegistry(
+		envelope.WithCompression(envelope.GzipCompressor{}, 0),
+		envelope.WithSigning(envelope.HMACSigner{KeyID: "one", Keys: map[string][]byte{"one": []byte("secret")}}),
+	)
+	_ = r.Register(&Test{}, &KeyedTest{})
+
+	tests := map[string]struct {
+		data         any
+		metadata     map[string]string
+		wantKey      string
+		wantMetadata map[string]string
+	}{
+		"key": {
+			data:    &Test{Test: "testing"},
+			wantKey: "envelope_test.Test",
+		},
+		"metadata": {
+			data:         &KeyedTest{Test: "testing"},
+			metadata:     map[string]string{"tenant_id": "123", "correlation_id": "abc"},
+			wantKey:      "test",
+			wantMetadata: map[string]string{"tenant_id": "123", "correlation_id": "abc"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			env, err := r.Serialize(tt.data, envelope.WithMetadata(tt.metadata))
+			if err != nil {
+				t.Fatalf("Registry.Serialize() error = %v", err)
+			}
+
+			key, err := envelope.PeekKey(env.Bytes())
+			if err != nil {
+				t.Fatalf("PeekKey() error = %v", err)
+			}
+			if key != tt.wantKey {
+				t.Errorf("PeekKey() = %v, want %v", key, tt.wantKey)
+			}
+
+			key, metadata, err := envelope.PeekHeader(env.Bytes())
+			if err != nil {
+				t.Fatalf("PeekHeader() error = %v", err)
+			}
+			if key != tt.wantKey {
+				t.Errorf("PeekHeader() key = %v, want %v", key, tt.wantKey)
+			}
+			if len(metadata) != len(tt.wantMetadata) {
+				t.Errorf("PeekHeader() metadata = %v, want %v", metadata, tt.wantMetadata)
+			}
+			for k, v := range tt.wantMetadata {
+				if metadata[k] != v {
+					t.Errorf("PeekHeader() metadata[%q] = %q, want %q", k, metadata[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestPeekKey_Malformed(t *testing.T) {
+	r := envelope.NewRegistry()
+	_ = r.Register(&Test{})
+	env, _ := r.Serialize(&Test{Test: "testing"})
+
+	tests := map[string][]byte{
+		"truncated":   env.Bytes()[:len(env.Bytes())-5],
+		"bad tag":     {0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"bad key":     {0x0a, 0x10, 'k'},
+		"missing key": {0x12, 0x01, 'p'},
+		"empty key":   {0x0a, 0x00},
+		"invalid key": {0x0a, 0x01, 0xff},
+	}
+
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := envelope.PeekKey(data); err == nil {
+				t.Errorf("PeekKey() expected an error")
+			}
+		})
+	}
+}
+
+func BenchmarkPeekKey(b *testing.B) {
+	r := envelope.NewRegistry()
+	_ = r.Register(&Test{})
+	env, err := r.Serialize(largeTest, envelope.WithMetadata(map[string]string{"tenant_id": "123"}))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("PeekKey", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := envelope.PeekKey(env.Bytes()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("PeekHeader", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, _, err := envelope.PeekHeader(env.Bytes()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("DeserializeLazy", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := r.DeserializeLazy(env.Bytes()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Deserialize", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := r.Deserialize(env.Bytes()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,120 @@
+package envelope
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// JsonEnvelopeSerde is an envelope Serde implementation for a readable JSON envelope
+//
+// Envelopes are serialized as JSON objects with the envelope key as the "type" and the
+// payload as the "data". JSON payloads are embedded as JSON, making the envelopes easy
+// to read and to query when stored in JSONB columns. Any other payloads, including
+// compressed or encrypted payloads, are stored base64 encoded in "data_base64".
+//
+// Signatures cover the exact bytes of the payload. JSONB columns change the whitespace
+// and key order of the embedded data, so signed envelopes will fail verification once
+// they have been stored in one; store signed envelopes in JSON or text columns instead.
+//
+//	{"type":"myEntity.userCreated","data":{"FirstName":"John","LastName":"Doe"},"version":1,"content_type":"application/json"}
+type JsonEnvelopeSerde struct{}
+
+type jsonEnvelope struct {
+	Type           string            `json:"type"`
+	Data           json.RawMessage   `json:"data,omitempty"`
+	DataBase64     []byte            `json:"data_base64,omitempty"`
+	Metadata       map[string]string `json:"metadata,omitempty"`
+	Version        *uint32           `json:"version,omitempty"`
+	ContentType    *string           `json:"content_type,omitempty"`
+	Compression    *string           `json:"compression,omitempty"`
+	KeyID          *string           `json:"key_id,omitempty"`
+	Signature      []byte            `json:"signature,omitempty"`
+	SignatureKeyID *string           `json:"signature_key_id,omitempty"`
+	Subject        *string           `json:"subject,omitempty"`
+}
+
+func (s JsonEnvelopeSerde) ContentType() string {
+	return "application/json"
+}
+
+func (s JsonEnvelopeSerde) Serialize(v any) ([]byte, error) {
+	msg := v.(*EnvelopeMsg)
+
+	env := jsonEnvelope{
+		Type:           msg.GetKey(),
+		Metadata:       msg.GetMetadata(),
+		Version:        msg.Version,
+		ContentType:    msg.ContentType,
+		Compression:    msg.Compression,
+		KeyID:          msg.KeyId,
+		Signature:      msg.GetSignature(),
+		SignatureKeyID: msg.SignatureKeyId,
+		Subject:        msg.Subject,
+	}
+
+	if isEmbeddable(msg) {
+		env.Data = msg.GetPayload()
+	} else {
+		env.DataBase64 = msg.GetPayload()
+	}
+
+	return marshalJSON(env)
+}
+
+func (s JsonEnvelopeSerde) Deserialize(data []byte, v any) error {
+	msg := v.(*EnvelopeMsg)
+
+	var env jsonEnvelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return err
+	}
+
+	msg.Key = &env.Type
+	msg.Metadata = env.Metadata
+	msg.Version = env.Version
+	msg.ContentType = env.ContentType
+	msg.Compression = env.Compression
+	msg.KeyId = env.KeyID
+	msg.Signature = env.Signature
+	msg.SignatureKeyId = env.SignatureKeyID
+	msg.Subject = env.Subject
+
+	if env.Data != nil {
+		msg.Payload = env.Data
+	} else {
+		msg.Payload = env.DataBase64
+	}
+
+	return nil
+}
+
+// isOpaque returns true when the payload cannot be read as its content type
+func isOpaque(msg *EnvelopeMsg) bool {
+	return msg.Compression != nil || msg.KeyId != nil || msg.Subject != nil
+}
+
+// isEmbeddable returns true when the payload is JSON that can be embedded without being changed
+func isEmbeddable(msg *EnvelopeMsg) bool {
+	if isOpaque(msg) || !isJSONContentType(msg.GetContentType()) {
+		return false
+	}
+
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, msg.GetPayload()); err != nil {
+		return false
+	}
+
+	return bytes.Equal(buf.Bytes(), msg.GetPayload())
+}
+
+// marshalJSON marshals the value without escaping HTML so embedded payloads are unchanged
+func marshalJSON(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,142 @@
+package envelope_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/stackus/envelope"
+)
+
+func TestJsonEnvelopeSerde(t *testing.T) {
+	keys := envelope.NewMemoryKeyProvider()
+	_ = keys.AddKey("one", keyOne)
+
+	tests := map[string]struct {
+		options  []envelope.RegistryOption
+		data     any
+		wantData []byte
+	}{
+		"embedded": {
+			data:     &Test{Test: "testing"},
+			wantData: []byte(`"data":{"Test":"testing"}`),
+		},
+		"embedded signed": {
+			options: []envelope.RegistryOption{envelope.WithSigning(envelope.HMACSigner{
+				KeyID: "one",
+				Keys:  map[string][]byte{"one": []byte("secret")},
+			})},
+			data:     &Test{Test: "<b>testing</b>"},
+			wantData: []byte(`"data":{"Test":"\u003cb\u003etesting\u003c/b\u003e"}`),
+		},
+		"encrypted": {
+			options:  []envelope.RegistryOption{envelope.WithEncryption(keys)},
+			data:     &Test{Test: "testing"},
+			wantData: []byte(`"data_base64":`),
+		},
+		"protobuf": {
+			data:     wrapperspb.String("testing"),
+			wantData: []byte(`"data_base64":`),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := envelope.NewRegistry(append(tt.options, envelope.WithEnvelopeSerde(envelope.JsonEnvelopeSerde{}))...)
+			_ = r.Register(&Test{})
+			_ = r.RegisterWith(&wrapperspb.StringValue{}, envelope.WithTypeSerde(envelope.ProtoSerde{}))
+
+			env, err := r.Serialize(tt.data, envelope.WithMetadata(map[string]string{"tenant_id": "123"}))
+			if err != nil {
+				t.Fatalf("Registry.Serialize() error = %v", err)
+			}
+			if !bytes.Contains(env.Bytes(), tt.wantData) {
+				t.Errorf("Registry.Serialize() = %s, want it to contain %s", env.Bytes(), tt.wantData)
+			}
+
+			received, err := r.Deserialize(env.Bytes())
+			if err != nil {
+				t.Fatalf("Registry.Deserialize() error = %v", err)
+			}
+			if received.Key() != env.Key() {
+				t.Errorf("Registry.Deserialize() key = %v, want %v", received.Key(), env.Key())
+			}
+			if received.Metadata()["tenant_id"] != "123" {
+				t.Errorf("Registry.Deserialize() metadata = %v", received.Metadata())
+			}
+		})
+	}
+}
+
+type JsonbTest struct {
+	Name string
+	Age  int
+}
+
+func TestJsonEnvelopeSerde_Jsonb(t *testing.T) {
+	signing := envelope.WithSigning(envelope.HMACSigner{
+		KeyID: "one",
+		Keys:  map[string][]byte{"one": []byte("secret")},
+	})
+	// jsonb mimics a JSONB column, which changes the whitespace and key order of the stored JSON
+	jsonb := func(data []byte) []byte {
+		var v any
+		if err := json.Unmarshal(data, &v); err != nil {
+			t.Fatalf("json.Unmarshal() error = %v", err)
+		}
+		data, err := json.MarshalIndent(v, "", " ")
+		if err != nil {
+			t.Fatalf("json.MarshalIndent() error = %v", err)
+		}
+		return data
+	}
+
+	tests := map[string]struct {
+		options []envelope.RegistryOption
+		store   func([]byte) []byte
+		wantErr error
+	}{
+		"unsigned": {
+			store: jsonb,
+		},
+		"signed unchanged": {
+			options: []envelope.RegistryOption{signing},
+			store:   func(data []byte) []byte { return data },
+		},
+		// signatures cover the exact payload bytes, so they cannot survive a JSONB column
+		"signed": {
+			options: []envelope.RegistryOption{signing},
+			store:   jsonb,
+			wantErr: envelope.ErrInvalidSignature("envelope_test.JsonbTest"),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := envelope.NewRegistry(append(tt.options, envelope.WithEnvelopeSerde(envelope.JsonEnvelopeSerde{}))...)
+			_ = r.Register(&JsonbTest{})
+
+			env, err := r.Serialize(&JsonbTest{Name: "testing", Age: 42})
+			if err != nil {
+				t.Fatalf("Registry.Serialize() error = %v", err)
+			}
+
+			received, err := r.Deserialize(tt.store(env.Bytes()))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Registry.Deserialize() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Registry.Deserialize() error = %v", err)
+			}
+			if got := *received.Payload().(*JsonbTest); got != (JsonbTest{Name: "testing", Age: 42}) {
+				t.Errorf("Registry.Deserialize() = %+v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package envelope
+
+import (
+	"context"
+	"sync"
+)
+
+type (
+	// LazyEnvelope is an Envelope that deserializes its payload the first time it is used
+	LazyEnvelope interface {
+		Envelope
+		// Decode deserializes the payload and returns it, or the error that prevented it from being deserialized
+		Decode() (any, error)
+	}
+
+	lazyEnvelope struct {
+		r       *registry
+		msg     *EnvelopeMsg
+		key     string
+		data    []byte
+		once    sync.Once
+		payload any
+		err     error
+	}
+)
+
+// DeserializeLazy deserializes a byte slice into an envelope without deserializing the payload.
+//
+// The key and metadata of the envelope are available immediately, making it cheap to route
+// or filter envelopes, while the payload is deserialized the first time Payload or Decode
+// is called. Payload returns nil when the payload could not be deserialized; use Decode
+// to get the error. The payload is only deserialized once.
+//
+// The type does not need to be registered to deserialize the envelope, however an
+// ErrUnregisteredKey error will be returned by Decode. Signatures are verified immediately.
+// DeserializeMiddleware is not run for lazily deserialized envelopes, while the Observer
+// observes both the deserialization of the envelope and the decoding of its payload.
+func (r *registry) DeserializeLazy(data []byte) (LazyEnvelope, error) {
+	if r.observer == nil {
+		return r.deserializeLazy(context.Background(), data)
+	}
+
+	env, err := r.observeDeserialize(func(ctx context.Context, data []byte) (Envelope, error) {
+		return r.deserializeLazy(ctx, data)
+	})(context.Background(), data)
+	if err != nil {
+		return nil, err
+	}
+	return env.(LazyEnvelope), nil
+}
+
+func (r *registry) deserializeLazy(_ context.Context, data []byte) (LazyEnvelope, error) {
+	msg, err := r.open(data)
+	if err != nil {
+		return nil, err
+	}
+
+	key := msg.GetKey()
+	if reg, exists := r.lookup(key); exists {
+		key = reg.key
+	}
+
+	return &lazyEnvelope{
+		r:    r,
+		msg:  msg,
+		key:  key,
+		data: data,
+	}, nil
+}
+
+func (e *lazyEnvelope) Key() string {
+	return e.key
+}
+
+func (e *lazyEnvelope) Payload() any {
+	payload, _ := e.Decode()
+	return payload
+}
+
+func (e *lazyEnvelope) Decode() (any, error) {
+	e.once.Do(func() {
+		if e.r.observer == nil {
+			_, e.payload, e.err = e.r.decode(e.msg)
+			return
+		}
+		e.r.observeDecode(context.Background(), e, func() error {
+			_, e.payload, e.err = e.r.decode(e.msg)
+			return e.err
+		})
+	})
+	return e.payload, e.err
+}
+
+func (e *lazyEnvelope) Metadata() map[string]string {
+	return e.msg.GetMetadata()
+}
+
+func (e *lazyEnvelope) Bytes() []byte {
+	return e.data
+}
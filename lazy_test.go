@@ -0,0 +1,101 @@
+package envelope_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stackus/envelope"
+)
+
+func TestRegistry_DeserializeLazy(t *testing.T) {
+	producer := envelope.NewRegistry()
+	_ = producer.Register(&Test{}, &KeyedTest{})
+
+	env, err := producer.Serialize(&Test{Test: "testing"}, envelope.WithMetadata(map[string]string{"tenant_id": "123"}))
+	if err != nil {
+		t.Fatalf("Registry.Serialize() error = %v", err)
+	}
+
+	t.Run("decoded once", func(t *testing.T) {
+		var built int
+		r := envelope.NewRegistry()
+		_ = r.RegisterFactory(func() any {
+			built++
+			return &Test{}
+		})
+		built = 0
+
+		lazy, err := r.DeserializeLazy(env.Bytes())
+		if err != nil {
+			t.Fatalf("Registry.DeserializeLazy() error = %v", err)
+		}
+		if lazy.Key() != "envelope_test.Test" || lazy.Metadata()["tenant_id"] != "123" {
+			t.Errorf("Registry.DeserializeLazy() = %v %v", lazy.Key(), lazy.Metadata())
+		}
+		if built != 0 {
+			t.Errorf("Registry.DeserializeLazy() built the payload before it was used")
+		}
+
+		if got := lazy.Payload().(*Test).Test; got != "testing" {
+			t.Errorf("LazyEnvelope.Payload() = %v, want %v", got, "testing")
+		}
+		if _, err = lazy.Decode(); err != nil {
+			t.Errorf("LazyEnvelope.Decode() error = %v", err)
+		}
+		if built != 1 {
+			t.Errorf("LazyEnvelope.Payload() built the payload %d times, want 1", built)
+		}
+	})
+
+	t.Run("unregistered", func(t *testing.T) {
+		r := envelope.NewRegistry()
+
+		lazy, err := r.DeserializeLazy(env.Bytes())
+		if err != nil {
+			t.Fatalf("Registry.DeserializeLazy() error = %v", err)
+		}
+		if lazy.Key() != "envelope_test.Test" {
+			t.Errorf("LazyEnvelope.Key() = %v, want %v", lazy.Key(), "envelope_test.Test")
+		}
+		if lazy.Payload() != nil {
+			t.Errorf("LazyEnvelope.Payload() = %v, want nil", lazy.Payload())
+		}
+		var unregistered envelope.ErrUnregisteredKey
+		if _, err = lazy.Decode(); !errors.As(err, &unregistered) {
+			t.Errorf("LazyEnvelope.Decode() error = %v, want %T", err, unregistered)
+		}
+	})
+
+	t.Run("alias", func(t *testing.T) {
+		r := envelope.NewRegistry()
+		_ = r.Register(&KeyedTest{})
+		_ = r.RegisterAlias("envelope_test.Test", &KeyedTest{})
+
+		lazy, err := r.DeserializeLazy(env.Bytes())
+		if err != nil {
+			t.Fatalf("Registry.DeserializeLazy() error = %v", err)
+		}
+		if lazy.Key() != "test" {
+			t.Errorf("LazyEnvelope.Key() = %v, want %v", lazy.Key(), "test")
+		}
+	})
+}
+
+func BenchmarkRegistry_DeserializeLazy(b *testing.B) {
+	r := envelope.NewRegistry()
+	_ = r.Register(&Test{})
+	env, err := r.Serialize(largeTest)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lazy, err := r.DeserializeLazy(env.Bytes())
+		if err != nil {
+			b.Fatal(err)
+		}
+		_ = lazy.Key()
+	}
+}
@@ -0,0 +1,44 @@
+package envelope
+
+import (
+	"context"
+)
+
+type (
+	// SerializeHandler serializes the value registered with key into an Envelope.
+	//
+	// The value is sealed with the key, which may be changed by middleware before it calls
+	// the next handler. An ErrKeyTypeMismatch error is returned when the key is registered
+	// for a type other than the type of the value.
+	SerializeHandler func(ctx context.Context, key string, v any, opts ...SerializeOption) (Envelope, error)
+
+	// SerializeMiddleware wraps a SerializeHandler.
+	//
+	// Middleware sees the key and value before the payload is serialized and the Envelope
+	// after it has been sealed. It may add metadata by passing a WithMetadata option to
+	// the next handler, or stop the serialization by returning an error.
+	SerializeMiddleware func(next SerializeHandler) SerializeHandler
+
+	// DeserializeHandler deserializes a byte slice into an Envelope.
+	DeserializeHandler func(ctx context.Context, data []byte) (Envelope, error)
+
+	// DeserializeMiddleware wraps a DeserializeHandler.
+	//
+	// Middleware sees the bytes before the envelope is opened and the Envelope after its
+	// payload has been deserialized. It may stop the deserialization by returning an error.
+	DeserializeMiddleware func(next DeserializeHandler) DeserializeHandler
+)
+
+func chainSerialize(h SerializeHandler, mws []SerializeMiddleware) SerializeHandler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+func chainDeserialize(h DeserializeHandler, mws []DeserializeMiddleware) DeserializeHandler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
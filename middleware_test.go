@@ -0,0 +1,248 @@
+package envelope_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stackus/envelope"
+)
+
+func recordSerialize(name string, calls *[]string) envelope.SerializeMiddleware {
+	return func(next envelope.SerializeHandler) envelope.SerializeHandler {
+		return func(ctx context.Context, key string, v any, opts ...envelope.SerializeOption) (envelope.Envelope, error) {
+			*calls = append(*calls, name+" before "+key)
+			env, err := next(ctx, key, v, opts...)
+			*calls = append(*calls, name+" after")
+			return env, err
+		}
+	}
+}
+
+func recordDeserialize(name string, calls *[]string) envelope.DeserializeMiddleware {
+	return func(next envelope.DeserializeHandler) envelope.DeserializeHandler {
+		return func(ctx context.Context, data []byte) (envelope.Envelope, error) {
+			*calls = append(*calls, name+" before")
+			env, err := next(ctx, data)
+			if err == nil {
+				*calls = append(*calls, name+" after "+env.Key())
+			}
+			return env, err
+		}
+	}
+}
+
+func TestWithSerializeMiddleware(t *testing.T) {
+	errRejected := errors.New("rejected")
+
+	tests := map[string]struct {
+		v            any
+		mws          func(calls *[]string) []envelope.SerializeMiddleware
+		wantCalls    []string
+		wantKey      string
+		wantMetadata map[string]string
+		wantErr      error
+	}{
+		"order": {
+			v: &Test{Test: "testing"},
+			mws: func(calls *[]string) []envelope.SerializeMiddleware {
+				return []envelope.SerializeMiddleware{recordSerialize("one", calls), recordSerialize("two", calls)}
+			},
+			wantCalls: []string{"one before envelope_test.Test", "two before envelope_test.Test", "two after", "one after"},
+		},
+		"canonical key": {
+			v: &LegacyTest{},
+			mws: func(calls *[]string) []envelope.SerializeMiddleware {
+				return []envelope.SerializeMiddleware{recordSerialize("one", calls)}
+			},
+			wantCalls: []string{"one before envelope_test.RenamedTest", "one after"},
+			wantErr:   envelope.ErrKeyTypeMismatch{Key: "envelope_test.RenamedTest", Type: "*envelope_test.LegacyTest"},
+		},
+		"metadata": {
+			v: &Test{Test: "testing"},
+			mws: func(*[]string) []envelope.SerializeMiddleware {
+				return []envelope.SerializeMiddleware{func(next envelope.SerializeHandler) envelope.SerializeHandler {
+					return func(ctx context.Context, key string, v any, opts ...envelope.SerializeOption) (envelope.Envelope, error) {
+						return next(ctx, key, v, append(opts, envelope.WithMetadata(map[string]string{"tenant_id": "123"}))...)
+					}
+				}}
+			},
+			wantMetadata: map[string]string{"tenant_id": "123", "correlation_id": "abc"},
+		},
+		"rekeyed": {
+			v: &RenamedTest{Test: "testing"},
+			mws: func(calls *[]string) []envelope.SerializeMiddleware {
+				return []envelope.SerializeMiddleware{
+					func(next envelope.SerializeHandler) envelope.SerializeHandler {
+						return func(ctx context.Context, _ string, v any, opts ...envelope.SerializeOption) (envelope.Envelope, error) {
+							return next(ctx, "legacy", v, opts...)
+						}
+					},
+					recordSerialize("one", calls),
+				}
+			},
+			wantCalls:    []string{"one before legacy", "one after"},
+			wantKey:      "envelope_test.RenamedTest",
+			wantMetadata: map[string]string{"correlation_id": "abc"},
+		},
+		"rekeyed to another type": {
+			v: &Test{Test: "testing"},
+			mws: func(*[]string) []envelope.SerializeMiddleware {
+				return []envelope.SerializeMiddleware{func(next envelope.SerializeHandler) envelope.SerializeHandler {
+					return func(ctx context.Context, _ string, v any, opts ...envelope.SerializeOption) (envelope.Envelope, error) {
+						return next(ctx, "legacy", v, opts...)
+					}
+				}}
+			},
+			wantErr: envelope.ErrKeyTypeMismatch{Key: "envelope_test.RenamedTest", Type: "*envelope_test.Test"},
+		},
+		"rejected": {
+			v: &Test{Test: "testing"},
+			mws: func(*[]string) []envelope.SerializeMiddleware {
+				return []envelope.SerializeMiddleware{func(envelope.SerializeHandler) envelope.SerializeHandler {
+					return func(context.Context, string, any, ...envelope.SerializeOption) (envelope.Envelope, error) {
+						return nil, errRejected
+					}
+				}}
+			},
+			wantErr: errRejected,
+		},
+		"unregistered": {
+			v: &KeyedTest{},
+			mws: func(calls *[]string) []envelope.SerializeMiddleware {
+				return []envelope.SerializeMiddleware{recordSerialize("one", calls)}
+			},
+			wantCalls: []string{"one before test", "one after"},
+			wantErr:   envelope.ErrUnregisteredKey("test"),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var calls []string
+			r := envelope.NewRegistry(envelope.WithSerializeMiddleware(tt.mws(&calls)...))
+			_ = r.Register(&Test{}, &RenamedTest{})
+
+			env, err := r.Serialize(tt.v, envelope.WithMetadata(map[string]string{"correlation_id": "abc"}))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Registry.Serialize() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(calls, tt.wantCalls) {
+				t.Errorf("Registry.Serialize() calls = %v, want %v", calls, tt.wantCalls)
+			}
+			if tt.wantKey != "" && env.Key() != tt.wantKey {
+				t.Errorf("Registry.Serialize() key = %v, want %v", env.Key(), tt.wantKey)
+			}
+			if tt.wantMetadata == nil {
+				return
+			}
+
+			received, err := r.Deserialize(env.Bytes())
+			if err != nil {
+				t.Fatalf("Registry.Deserialize() error = %v", err)
+			}
+			if !reflect.DeepEqual(received.Metadata(), tt.wantMetadata) {
+				t.Errorf("Registry.Deserialize() metadata = %v, want %v", received.Metadata(), tt.wantMetadata)
+			}
+		})
+	}
+}
+
+func TestWithDeserializeMiddleware(t *testing.T) {
+	errRejected := errors.New("rejected")
+
+	producer := envelope.NewRegistry()
+	_ = producer.Register(&Test{})
+	env, err := producer.Serialize(&Test{Test: "testing"})
+	if err != nil {
+		t.Fatalf("Registry.Serialize() error = %v", err)
+	}
+
+	tests := map[string]struct {
+		data      []byte
+		mws       func(calls *[]string) []envelope.DeserializeMiddleware
+		wantCalls []string
+		wantErr   error
+	}{
+		"order": {
+			data: env.Bytes(),
+			mws: func(calls *[]string) []envelope.DeserializeMiddleware {
+				return []envelope.DeserializeMiddleware{recordDeserialize("one", calls), recordDeserialize("two", calls)}
+			},
+			wantCalls: []string{"one before", "two before", "two after envelope_test.Test", "one after envelope_test.Test"},
+		},
+		"rejected": {
+			data: env.Bytes(),
+			mws: func(calls *[]string) []envelope.DeserializeMiddleware {
+				return []envelope.DeserializeMiddleware{
+					recordDeserialize("one", calls),
+					func(envelope.DeserializeHandler) envelope.DeserializeHandler {
+						return func(context.Context, []byte) (envelope.Envelope, error) {
+							return nil, errRejected
+						}
+					},
+					recordDeserialize("two", calls),
+				}
+			},
+			wantCalls: []string{"one before"},
+			wantErr:   errRejected,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var calls []string
+			r := envelope.NewRegistry(envelope.WithDeserializeMiddleware(tt.mws(&calls)...))
+			_ = r.Register(&Test{})
+
+			received, err := r.Deserialize(tt.data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Registry.Deserialize() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(calls, tt.wantCalls) {
+				t.Errorf("Registry.Deserialize() calls = %v, want %v", calls, tt.wantCalls)
+			}
+			if err == nil && received.Payload().(*Test).Test != "testing" {
+				t.Errorf("Registry.Deserialize() payload = %v", received.Payload())
+			}
+		})
+	}
+}
+
+func TestRegistry_Context(t *testing.T) {
+	type ctxKey struct{}
+
+	var got []any
+	r := envelope.NewRegistry(
+		envelope.WithSerializeMiddleware(func(next envelope.SerializeHandler) envelope.SerializeHandler {
+			return func(ctx context.Context, key string, v any, opts ...envelope.SerializeOption) (envelope.Envelope, error) {
+				got = append(got, ctx.Value(ctxKey{}))
+				return next(ctx, key, v, opts...)
+			}
+		}),
+		envelope.WithDeserializeMiddleware(func(next envelope.DeserializeHandler) envelope.DeserializeHandler {
+			return func(ctx context.Context, data []byte) (envelope.Envelope, error) {
+				got = append(got, ctx.Value(ctxKey{}))
+				return next(ctx, data)
+			}
+		}),
+	)
+	_ = r.Register(&Test{})
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+	env, err := r.SerializeContext(ctx, &Test{Test: "testing"})
+	if err != nil {
+		t.Fatalf("Registry.SerializeContext() error = %v", err)
+	}
+	if _, err = r.DeserializeContext(ctx, env.Bytes()); err != nil {
+		t.Fatalf("Registry.DeserializeContext() error = %v", err)
+	}
+	if _, err = r.Deserialize(env.Bytes()); err != nil {
+		t.Fatalf("Registry.Deserialize() error = %v", err)
+	}
+
+	if want := []any{"value", "value", nil}; !reflect.DeepEqual(got, want) {
+		t.Errorf("middleware context values = %v, want %v", got, want)
+	}
+}
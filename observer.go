@@ -0,0 +1,186 @@
+package envelope
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+type (
+	// Observer receives callbacks for the serialize and deserialize operations of a registry.
+	//
+	// Observers are called for every Serialize and Deserialize, including those made for
+	// batches, streams, and the context variants, and must be safe for concurrent use.
+	// Opening an envelope with DeserializeLazy is observed as a Deserialize, and decoding
+	// its payload is observed separately as a Decode.
+	Observer interface {
+		SerializeStarted(ctx context.Context, key string)
+		SerializeFinished(ctx context.Context, o Observation)
+		DeserializeStarted(ctx context.Context, size int)
+		DeserializeFinished(ctx context.Context, o Observation)
+		DecodeStarted(ctx context.Context, key string)
+		DecodeFinished(ctx context.Context, o Observation)
+	}
+
+	// Observation describes a finished serialize or deserialize operation.
+	Observation struct {
+		Key      string        // Key is the key of the value or envelope; empty when it is not known
+		Size     int           // Size is the size of the serialized envelope in bytes
+		Duration time.Duration // Duration is how long the operation took
+		Err      error         // Err is the error returned by the operation
+	}
+)
+
+// Error kinds returned by ErrorKind.
+const (
+	ErrorKindUnregistered = "unregistered"
+	ErrorKindSignature    = "signature"
+	ErrorKindEncryption   = "encryption"
+	ErrorKindForgotten    = "forgotten"
+	ErrorKindVersion      = "version"
+	ErrorKindContentType  = "content_type"
+	ErrorKindCompression  = "compression"
+	ErrorKindMetadata     = "metadata"
+	ErrorKindTooLarge     = "too_large"
+	ErrorKindValidation   = "validation"
+	ErrorKindPayload      = "payload"
+	ErrorKindEnvelope     = "envelope"
+	ErrorKindOther        = "other"
+)
+
+// ErrorKind returns a short, low cardinality, description of the kind of the error
+// suitable for use as a metric label.
+//
+// Errors that are not one of the typed errors of this package are described by the
+// Stage of the SerdeError that wraps them. An empty string is returned for a nil error.
+func ErrorKind(err error) string {
+	var (
+		unregistered     ErrUnregisteredKey
+		signature        ErrInvalidSignature
+		keyNotFound      ErrKeyNotFound
+		noKeyProvider    ErrNoKeyProvider
+		decryption       ErrDecryptionFailed
+		forgotten        ErrSubjectForgotten
+		missingUpcaster  ErrMissingUpcaster
+		unsupported      ErrUnsupportedVersion
+		contentType      ErrContentTypeMismatch
+		compression      ErrUnknownCompression
+		reservedMetadata ErrReservedMetadata
+		tooLarge         ErrPayloadTooLarge
+		validation       ErrValidation
+		serdeErr         *SerdeError
+	)
+
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &unregistered):
+		return ErrorKindUnregistered
+	case errors.As(err, &signature):
+		return ErrorKindSignature
+	case errors.As(err, &keyNotFound), errors.As(err, &noKeyProvider), errors.As(err, &decryption):
+		return ErrorKindEncryption
+	case errors.As(err, &forgotten):
+		return ErrorKindForgotten
+	case errors.As(err, &missingUpcaster), errors.As(err, &unsupported):
+		return ErrorKindVersion
+	case errors.As(err, &contentType):
+		return ErrorKindContentType
+	case errors.As(err, &compression):
+		return ErrorKindCompression
+	case errors.As(err, &reservedMetadata):
+		return ErrorKindMetadata
+	case errors.As(err, &tooLarge):
+		return ErrorKindTooLarge
+	case errors.As(err, &validation):
+		return ErrorKindValidation
+	case errors.As(err, &serdeErr):
+		return string(serdeErr.Stage)
+	default:
+		return ErrorKindOther
+	}
+}
+
+func (r *registry) observeSerialize(next SerializeHandler) SerializeHandler {
+	return func(ctx context.Context, key string, v any, opts ...SerializeOption) (Envelope, error) {
+		r.observer.SerializeStarted(ctx, key)
+		start := time.Now()
+
+		env, err := next(ctx, key, v, opts...)
+
+		o := Observation{
+			Key:      key,
+			Duration: time.Since(start),
+			Err:      err,
+		}
+		if env != nil {
+			o.Size = len(env.Bytes())
+		}
+		r.observer.SerializeFinished(ctx, o)
+
+		return env, err
+	}
+}
+
+func (r *registry) observeDeserialize(next DeserializeHandler) DeserializeHandler {
+	return func(ctx context.Context, data []byte) (Envelope, error) {
+		r.observer.DeserializeStarted(ctx, len(data))
+		start := time.Now()
+
+		env, err := next(ctx, data)
+
+		o := Observation{
+			Size:     len(data),
+			Duration: time.Since(start),
+			Err:      err,
+		}
+		if env != nil {
+			// lazily deserialized envelopes may have unregistered keys
+			o.Key = r.observedKey(env.Key())
+		} else {
+			o.Key = r.peekKey(data)
+		}
+		r.observer.DeserializeFinished(ctx, o)
+
+		return env, err
+	}
+}
+
+func (r *registry) observeDecode(ctx context.Context, e *lazyEnvelope, decode func() error) {
+	key := r.observedKey(e.key)
+	r.observer.DecodeStarted(ctx, key)
+	start := time.Now()
+
+	err := decode()
+
+	r.observer.DecodeFinished(ctx, Observation{
+		Key:      key,
+		Size:     len(e.data),
+		Duration: time.Since(start),
+		Err:      err,
+	})
+}
+
+// peekKey returns the canonical key of an envelope that could not be deserialized
+//
+// Unregistered keys are not returned; they come from outside the registry and would
+// make poor metric labels.
+func (r *registry) peekKey(data []byte) string {
+	if _, ok := r.envelopeSerde.(ProtoSerde); !ok {
+		return ""
+	}
+	key, err := PeekKey(data)
+	if err != nil {
+		return ""
+	}
+	return r.observedKey(key)
+}
+
+// observedKey returns the canonical key of a registered key, or an empty string for
+// an unregistered key
+func (r *registry) observedKey(key string) string {
+	if reg, exists := r.lookup(key); exists {
+		return reg.key
+	}
+	return ""
+}
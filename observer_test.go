@@ -0,0 +1,247 @@
+package envelope_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/stackus/envelope"
+)
+
+type recordingObserver struct {
+	mu       sync.Mutex
+	started  []string
+	finished []envelope.Observation
+}
+
+func (o *recordingObserver) SerializeStarted(_ context.Context, key string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.started = append(o.started, "serialize "+key)
+}
+
+func (o *recordingObserver) SerializeFinished(_ context.Context, obs envelope.Observation) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.finished = append(o.finished, obs)
+}
+
+func (o *recordingObserver) DeserializeStarted(_ context.Context, size int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.started = append(o.started, fmt.Sprintf("deserialize %d", size))
+}
+
+func (o *recordingObserver) DeserializeFinished(_ context.Context, obs envelope.Observation) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.finished = append(o.finished, obs)
+}
+
+func (o *recordingObserver) DecodeStarted(_ context.Context, key string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.started = append(o.started, "decode "+key)
+}
+
+func (o *recordingObserver) DecodeFinished(_ context.Context, obs envelope.Observation) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.finished = append(o.finished, obs)
+}
+
+func TestWithObserver(t *testing.T) {
+	keys := map[string][]byte{"one": []byte("secret")}
+
+	producer := envelope.NewRegistry(envelope.WithSigning(envelope.HMACSigner{KeyID: "one", Keys: keys}))
+	_ = producer.Register(&Test{}, &KeyedTest{})
+	signed, err := producer.Serialize(&Test{Test: "testing"})
+	if err != nil {
+		t.Fatalf("Registry.Serialize() error = %v", err)
+	}
+	unregistered, err := producer.Serialize(&KeyedTest{Test: "testing"})
+	if err != nil {
+		t.Fatalf("Registry.Serialize() error = %v", err)
+	}
+
+	tests := map[string]struct {
+		op       func(r envelope.Registry) error
+		wantKey  string
+		wantSize bool
+		wantKind string
+	}{
+		"serialize": {
+			op: func(r envelope.Registry) error {
+				_, err := r.Serialize(&Test{Test: "testing"})
+				return err
+			},
+			wantKey:  "envelope_test.Test",
+			wantSize: true,
+		},
+		"serialize canonical key": {
+			op: func(r envelope.Registry) error {
+				_, err := r.Serialize(&LegacyTest{})
+				return err
+			},
+			wantKey:  "envelope_test.RenamedTest",
+			wantKind: string(envelope.StageLookup),
+		},
+		"serialize unregistered": {
+			op: func(r envelope.Registry) error {
+				_, err := r.Serialize(&KeyedTest{})
+				return err
+			},
+			wantKey:  "test",
+			wantKind: envelope.ErrorKindUnregistered,
+		},
+		"deserialize": {
+			op: func(r envelope.Registry) error {
+				_, err := r.DeserializeContext(context.Background(), signed.Bytes())
+				return err
+			},
+			wantKey:  "envelope_test.Test",
+			wantSize: true,
+		},
+		"deserialize invalid signature": {
+			op: func(r envelope.Registry) error {
+				_, err := r.Deserialize(append(signed.Bytes()[:len(signed.Bytes()):len(signed.Bytes())], 0x12, 0x00))
+				return err
+			},
+			wantKey:  "envelope_test.Test",
+			wantSize: true,
+			wantKind: envelope.ErrorKindSignature,
+		},
+		"deserialize unregistered": {
+			op: func(r envelope.Registry) error {
+				_, err := r.Deserialize(unregistered.Bytes())
+				return err
+			},
+			wantSize: true,
+			wantKind: envelope.ErrorKindUnregistered,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			observer := &recordingObserver{}
+			r := envelope.NewRegistry(
+				envelope.WithObserver(observer),
+				envelope.WithSigning(envelope.HMACSigner{KeyID: "one", Keys: keys}),
+			)
+			_ = r.Register(&Test{}, &RenamedTest{})
+
+			err := tt.op(r)
+			if got := envelope.ErrorKind(err); got != tt.wantKind {
+				t.Fatalf("ErrorKind() = %q, want %q; error = %v", got, tt.wantKind, err)
+			}
+
+			if len(observer.started) != 1 || len(observer.finished) != 1 {
+				t.Fatalf("observer started = %v, finished = %v, want one each", observer.started, observer.finished)
+			}
+			obs := observer.finished[0]
+			if obs.Key != tt.wantKey {
+				t.Errorf("Observation.Key = %q, want %q", obs.Key, tt.wantKey)
+			}
+			if (obs.Size > 0) != tt.wantSize {
+				t.Errorf("Observation.Size = %d, want size %v", obs.Size, tt.wantSize)
+			}
+			if obs.Duration <= 0 {
+				t.Errorf("Observation.Duration = %v, want > 0", obs.Duration)
+			}
+			if !errors.Is(obs.Err, err) {
+				t.Errorf("Observation.Err = %v, want %v", obs.Err, err)
+			}
+		})
+	}
+}
+
+func TestWithObserver_Lazy(t *testing.T) {
+	producer := envelope.NewRegistry()
+	_ = producer.Register(&Test{}, &KeyedTest{})
+	registered, err := producer.Serialize(&Test{Test: "testing"})
+	if err != nil {
+		t.Fatalf("Registry.Serialize() error = %v", err)
+	}
+	unregistered, err := producer.Serialize(&KeyedTest{Test: "testing"})
+	if err != nil {
+		t.Fatalf("Registry.Serialize() error = %v", err)
+	}
+
+	tests := map[string]struct {
+		data        []byte
+		wantStarted []string
+		wantKinds   []string
+	}{
+		"registered": {
+			data:        registered.Bytes(),
+			wantStarted: []string{fmt.Sprintf("deserialize %d", len(registered.Bytes())), "decode envelope_test.Test"},
+			wantKinds:   []string{"", ""},
+		},
+		"unregistered": {
+			data:        unregistered.Bytes(),
+			wantStarted: []string{fmt.Sprintf("deserialize %d", len(unregistered.Bytes())), "decode "},
+			wantKinds:   []string{"", envelope.ErrorKindUnregistered},
+		},
+		"malformed": {
+			data:        []byte{0xff},
+			wantStarted: []string{"deserialize 1"},
+			wantKinds:   []string{envelope.ErrorKindEnvelope},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			observer := &recordingObserver{}
+			r := envelope.NewRegistry(envelope.WithObserver(observer))
+			_ = r.Register(&Test{})
+
+			if lazy, err := r.DeserializeLazy(tt.data); err == nil {
+				_, _ = lazy.Decode()
+				// the payload is only decoded, and observed, once
+				_ = lazy.Payload()
+			}
+
+			if !reflect.DeepEqual(observer.started, tt.wantStarted) {
+				t.Errorf("observer started = %v, want %v", observer.started, tt.wantStarted)
+			}
+			var kinds []string
+			for _, obs := range observer.finished {
+				kinds = append(kinds, envelope.ErrorKind(obs.Err))
+			}
+			if !reflect.DeepEqual(kinds, tt.wantKinds) {
+				t.Errorf("observer finished kinds = %v, want %v", kinds, tt.wantKinds)
+			}
+		})
+	}
+}
+
+func TestErrorKind(t *testing.T) {
+	tests := map[string]struct {
+		err  error
+		want string
+	}{
+		"nil":          {err: nil, want: ""},
+		"unregistered": {err: envelope.ErrUnregisteredKey("test"), want: envelope.ErrorKindUnregistered},
+		"signature":    {err: envelope.ErrInvalidSignature("test"), want: envelope.ErrorKindSignature},
+		"encryption":   {err: envelope.ErrDecryptionFailed("test"), want: envelope.ErrorKindEncryption},
+		"forgotten":    {err: envelope.ErrSubjectForgotten{Key: "test"}, want: envelope.ErrorKindForgotten},
+		"version":      {err: envelope.ErrMissingUpcaster{Key: "test"}, want: envelope.ErrorKindVersion},
+		"content type": {err: envelope.ErrContentTypeMismatch{Key: "test"}, want: envelope.ErrorKindContentType},
+		"compression":  {err: envelope.ErrUnknownCompression{Key: "test"}, want: envelope.ErrorKindCompression},
+		"metadata":     {err: envelope.ErrReservedMetadata("test"), want: envelope.ErrorKindMetadata},
+		"wrapped":      {err: fmt.Errorf("wrapped: %w", envelope.ErrUnregisteredKey("test")), want: envelope.ErrorKindUnregistered},
+		"stage":        {err: &envelope.SerdeError{Stage: envelope.StagePayload, Err: errors.New("payload")}, want: envelope.ErrorKindPayload},
+		"other":        {err: errors.New("other"), want: envelope.ErrorKindOther},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := envelope.ErrorKind(tt.err); got != tt.want {
+				t.Errorf("ErrorKind() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
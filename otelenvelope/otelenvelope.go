@@ -0,0 +1,68 @@
+// Package otelenvelope propagates OpenTelemetry trace context through envelope metadata.
+//
+// The W3C traceparent and tracestate of the context passed to SerializeContext are sealed
+// into the metadata of the envelope by the SerializeMiddleware, and Extract returns a
+// context carrying the remote span context of a deserialized envelope.
+package otelenvelope
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/stackus/envelope"
+)
+
+type (
+	Option func(*config)
+
+	config struct {
+		propagator propagation.TextMapPropagator
+	}
+)
+
+// WithPropagator sets the propagator used to inject and extract the trace context.
+//
+// The W3C TraceContext propagator is used by default.
+func WithPropagator(propagator propagation.TextMapPropagator) Option {
+	return func(c *config) {
+		c.propagator = propagator
+	}
+}
+
+func newConfig(opts ...Option) *config {
+	c := &config{
+		propagator: propagation.TraceContext{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// SerializeMiddleware injects the trace context of the context into the envelope metadata.
+//
+// Nothing is injected when the context does not carry a valid span context.
+func SerializeMiddleware(opts ...Option) envelope.SerializeMiddleware {
+	cfg := newConfig(opts...)
+
+	return func(next envelope.SerializeHandler) envelope.SerializeHandler {
+		return func(ctx context.Context, key string, v any, opts ...envelope.SerializeOption) (envelope.Envelope, error) {
+			carrier := propagation.MapCarrier{}
+			cfg.propagator.Inject(ctx, carrier)
+			if len(carrier) != 0 {
+				opts = append(opts, envelope.WithMetadata(carrier))
+			}
+			return next(ctx, key, v, opts...)
+		}
+	}
+}
+
+// Extract returns a copy of the context carrying the trace context sealed in the envelope metadata.
+//
+// The context is returned unchanged when the envelope does not carry a trace context.
+func Extract(ctx context.Context, env envelope.Envelope, opts ...Option) context.Context {
+	cfg := newConfig(opts...)
+
+	return cfg.propagator.Extract(ctx, propagation.MapCarrier(env.Metadata()))
+}
@@ -0,0 +1,119 @@
+package otelenvelope_test
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/stackus/envelope"
+	"github.com/stackus/envelope/otelenvelope"
+)
+
+type Test struct {
+	Test string
+}
+
+func TestPropagation(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	tracer := tp.Tracer("otelenvelope_test")
+
+	tests := map[string]struct {
+		opts      []otelenvelope.Option
+		traced    bool
+		wantState string
+	}{
+		"traced": {
+			traced: true,
+		},
+		"trace state": {
+			traced:    true,
+			wantState: "vendor=value",
+		},
+		"untraced": {
+			traced: false,
+		},
+		"propagator": {
+			opts:   []otelenvelope.Option{otelenvelope.WithPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))},
+			traced: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			exporter.Reset()
+
+			r := envelope.NewRegistry(envelope.WithSerializeMiddleware(otelenvelope.SerializeMiddleware(tt.opts...)))
+			_ = r.Register(&Test{})
+
+			ctx := context.Background()
+			var producer trace.Span
+			if tt.traced {
+				if tt.wantState != "" {
+					state, err := trace.ParseTraceState(tt.wantState)
+					if err != nil {
+						t.Fatalf("trace.ParseTraceState() error = %v", err)
+					}
+					ctx = trace.ContextWithSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+						TraceID:    trace.TraceID{1},
+						SpanID:     trace.SpanID{1},
+						TraceFlags: trace.FlagsSampled,
+						TraceState: state,
+					}))
+				}
+				ctx, producer = tracer.Start(ctx, "produce")
+			}
+
+			env, err := r.SerializeContext(ctx, &Test{Test: "testing"}, envelope.WithMetadata(map[string]string{"tenant_id": "123"}))
+			if err != nil {
+				t.Fatalf("Registry.SerializeContext() error = %v", err)
+			}
+			if producer != nil {
+				producer.End()
+			}
+
+			received, err := r.DeserializeContext(context.Background(), env.Bytes())
+			if err != nil {
+				t.Fatalf("Registry.DeserializeContext() error = %v", err)
+			}
+			if received.Metadata()["tenant_id"] != "123" {
+				t.Errorf("Registry.DeserializeContext() metadata = %v", received.Metadata())
+			}
+
+			_, consumer := tracer.Start(otelenvelope.Extract(context.Background(), received, tt.opts...), "consume")
+			consumer.End()
+
+			spans := exporter.GetSpans()
+			if !tt.traced {
+				if _, exists := received.Metadata()["traceparent"]; exists {
+					t.Errorf("Registry.SerializeContext() injected traceparent without a span")
+				}
+				if len(spans) != 1 || spans[0].Parent.IsValid() {
+					t.Errorf("Extract() spans = %v, want a single root span", spans)
+				}
+				return
+			}
+
+			if len(spans) != 2 {
+				t.Fatalf("spans = %d, want 2", len(spans))
+			}
+			produced, consumed := spans[0], spans[1]
+			if consumed.SpanContext.TraceID() != produced.SpanContext.TraceID() {
+				t.Errorf("Extract() trace id = %v, want %v", consumed.SpanContext.TraceID(), produced.SpanContext.TraceID())
+			}
+			if consumed.Parent.SpanID() != produced.SpanContext.SpanID() {
+				t.Errorf("Extract() parent span id = %v, want %v", consumed.Parent.SpanID(), produced.SpanContext.SpanID())
+			}
+			if !consumed.Parent.IsRemote() {
+				t.Errorf("Extract() parent is not remote")
+			}
+			if got := consumed.Parent.TraceState().String(); got != tt.wantState {
+				t.Errorf("Extract() trace state = %q, want %q", got, tt.wantState)
+			}
+		})
+	}
+}
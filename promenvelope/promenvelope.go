@@ -0,0 +1,163 @@
+// Package promenvelope reports the operations of an envelope registry as Prometheus metrics.
+//
+// The Observer is registered with Prometheus as a collector and passed to the registry
+// with the envelope.WithObserver option.
+package promenvelope
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/stackus/envelope"
+)
+
+const (
+	opSerialize   = "serialize"
+	opDeserialize = "deserialize"
+	opDecode      = "decode"
+)
+
+type (
+	Option func(*config)
+
+	config struct {
+		namespace       string
+		durationBuckets []float64
+		sizeBuckets     []float64
+	}
+
+	// Observer is an envelope.Observer and a prometheus.Collector.
+	//
+	// The following metrics are collected, labelled by operation and key, where the payloads
+	// of lazily deserialized envelopes are reported as a separate decode operation:
+	//   - envelope_operation_duration_seconds; a histogram of how long operations took
+	//   - envelope_size_bytes; a histogram of the sizes of the serialized envelopes
+	//   - envelope_errors_total; a counter of the failed operations, also labelled by kind
+	//   - envelope_operations_in_flight; a gauge of the operations in progress, labelled only by operation
+	Observer struct {
+		duration *prometheus.HistogramVec
+		size     *prometheus.HistogramVec
+		errors   *prometheus.CounterVec
+		inFlight *prometheus.GaugeVec
+	}
+)
+
+var _ interface {
+	envelope.Observer
+	prometheus.Collector
+} = (*Observer)(nil)
+
+// WithNamespace prefixes the metric names with the namespace.
+func WithNamespace(namespace string) Option {
+	return func(c *config) {
+		c.namespace = namespace
+	}
+}
+
+// WithDurationBuckets sets the buckets of the duration histogram.
+//
+// The prometheus.DefBuckets are used by default.
+func WithDurationBuckets(buckets ...float64) Option {
+	return func(c *config) {
+		c.durationBuckets = buckets
+	}
+}
+
+// WithSizeBuckets sets the buckets of the size histogram.
+//
+// Buckets from 64 bytes to 1 MiB, growing by a factor of four, are used by default.
+func WithSizeBuckets(buckets ...float64) Option {
+	return func(c *config) {
+		c.sizeBuckets = buckets
+	}
+}
+
+// NewObserver creates a new Observer.
+func NewObserver(opts ...Option) *Observer {
+	cfg := &config{
+		durationBuckets: prometheus.DefBuckets,
+		sizeBuckets:     prometheus.ExponentialBuckets(64, 4, 8),
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return &Observer{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: cfg.namespace,
+			Subsystem: "envelope",
+			Name:      "operation_duration_seconds",
+			Help:      "How long envelope operations took.",
+			Buckets:   cfg.durationBuckets,
+		}, []string{"operation", "key"}),
+		size: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: cfg.namespace,
+			Subsystem: "envelope",
+			Name:      "size_bytes",
+			Help:      "The sizes of serialized envelopes.",
+			Buckets:   cfg.sizeBuckets,
+		}, []string{"operation", "key"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: cfg.namespace,
+			Subsystem: "envelope",
+			Name:      "errors_total",
+			Help:      "The number of failed envelope operations.",
+		}, []string{"operation", "key", "kind"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: cfg.namespace,
+			Subsystem: "envelope",
+			Name:      "operations_in_flight",
+			Help:      "The number of envelope operations in progress.",
+		}, []string{"operation"}),
+	}
+}
+
+func (o *Observer) SerializeStarted(context.Context, string) {
+	o.inFlight.WithLabelValues(opSerialize).Inc()
+}
+
+func (o *Observer) SerializeFinished(_ context.Context, obs envelope.Observation) {
+	o.finished(opSerialize, obs)
+}
+
+func (o *Observer) DeserializeStarted(context.Context, int) {
+	o.inFlight.WithLabelValues(opDeserialize).Inc()
+}
+
+func (o *Observer) DeserializeFinished(_ context.Context, obs envelope.Observation) {
+	o.finished(opDeserialize, obs)
+}
+
+func (o *Observer) DecodeStarted(context.Context, string) {
+	o.inFlight.WithLabelValues(opDecode).Inc()
+}
+
+func (o *Observer) DecodeFinished(_ context.Context, obs envelope.Observation) {
+	o.finished(opDecode, obs)
+}
+
+func (o *Observer) Describe(ch chan<- *prometheus.Desc) {
+	o.duration.Describe(ch)
+	o.size.Describe(ch)
+	o.errors.Describe(ch)
+	o.inFlight.Describe(ch)
+}
+
+func (o *Observer) Collect(ch chan<- prometheus.Metric) {
+	o.duration.Collect(ch)
+	o.size.Collect(ch)
+	o.errors.Collect(ch)
+	o.inFlight.Collect(ch)
+}
+
+func (o *Observer) finished(op string, obs envelope.Observation) {
+	o.inFlight.WithLabelValues(op).Dec()
+	o.duration.WithLabelValues(op, obs.Key).Observe(obs.Duration.Seconds())
+	if obs.Size > 0 {
+		o.size.WithLabelValues(op, obs.Key).Observe(float64(obs.Size))
+	}
+	if obs.Err != nil {
+		o.errors.WithLabelValues(op, obs.Key, envelope.ErrorKind(obs.Err)).Inc()
+	}
+}
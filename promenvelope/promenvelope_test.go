@@ -0,0 +1,97 @@
+package promenvelope_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/stackus/envelope"
+	"github.com/stackus/envelope/promenvelope"
+)
+
+type Test struct {
+	Test string
+}
+
+type Unregistered struct{}
+
+func TestObserver(t *testing.T) {
+	observer := promenvelope.NewObserver(promenvelope.WithNamespace("app"))
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(observer); err != nil {
+		t.Fatalf("Registry.Register() error = %v", err)
+	}
+
+	r := envelope.NewRegistry(envelope.WithObserver(observer))
+	_ = r.Register(&Test{})
+
+	env, err := r.Serialize(&Test{Test: "testing"})
+	if err != nil {
+		t.Fatalf("Registry.Serialize() error = %v", err)
+	}
+	if _, err = r.Deserialize(env.Bytes()); err != nil {
+		t.Fatalf("Registry.Deserialize() error = %v", err)
+	}
+	_, _ = r.Serialize(&Unregistered{})
+	_, _ = r.Deserialize([]byte("garbage"))
+
+	producer := envelope.NewRegistry()
+	_ = producer.Register(&Unregistered{})
+	unregistered, err := producer.Serialize(&Unregistered{})
+	if err != nil {
+		t.Fatalf("Registry.Serialize() error = %v", err)
+	}
+	for _, data := range [][]byte{env.Bytes(), unregistered.Bytes()} {
+		lazy, err := r.DeserializeLazy(data)
+		if err != nil {
+			t.Fatalf("Registry.DeserializeLazy() error = %v", err)
+		}
+		_, _ = lazy.Decode()
+	}
+
+	if err = testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP app_envelope_errors_total The number of failed envelope operations.
+# TYPE app_envelope_errors_total counter
+app_envelope_errors_total{key="",kind="unregistered",operation="decode"} 1
+app_envelope_errors_total{key="",kind="envelope",operation="deserialize"} 1
+app_envelope_errors_total{key="promenvelope_test.Unregistered",kind="unregistered",operation="serialize"} 1
+# HELP app_envelope_operations_in_flight The number of envelope operations in progress.
+# TYPE app_envelope_operations_in_flight gauge
+app_envelope_operations_in_flight{operation="decode"} 0
+app_envelope_operations_in_flight{operation="deserialize"} 0
+app_envelope_operations_in_flight{operation="serialize"} 0
+`), "app_envelope_errors_total", "app_envelope_operations_in_flight"); err != nil {
+		t.Error(err)
+	}
+
+	tests := map[string]struct {
+		metric string
+		want   int
+	}{
+		"duration": {metric: "app_envelope_operation_duration_seconds", want: 8},
+		"size":     {metric: "app_envelope_size_bytes", want: 7},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			families, err := reg.Gather()
+			if err != nil {
+				t.Fatalf("Registry.Gather() error = %v", err)
+			}
+			var got uint64
+			for _, family := range families {
+				if family.GetName() != tt.metric {
+					continue
+				}
+				for _, metric := range family.GetMetric() {
+					got += metric.GetHistogram().GetSampleCount()
+				}
+			}
+			if got != uint64(tt.want) {
+				t.Errorf("%s samples = %d, want %d", tt.metric, got, tt.want)
+			}
+		})
+	}
+}
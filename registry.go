@@ -1,23 +1,38 @@
 package envelope
 
 import (
+	"context"
+	"fmt"
 	"reflect"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
 )
 
 type (
 	Envelope interface {
-		Key() string   // Key returns the key of the envelope value
-		Payload() any  // Payload returns the value of the envelope
-		Bytes() []byte // Bytes returns the serialized envelope containing the key and payload
+		Key() string                 // Key returns the key of the envelope value
+		Payload() any                // Payload returns the value of the envelope
+		Metadata() map[string]string // Metadata returns the metadata that was sealed with the envelope
+		Bytes() []byte               // Bytes returns the serialized envelope containing the key and payload
 	}
 
 	Registry interface {
 		Register(vs ...any) error
 		RegisterFactory(fns ...func() any) error
-		Serialize(v any) (Envelope, error)
+		RegisterWith(v any, opts ...TypeOption) error
+		Serialize(v any, opts ...SerializeOption) (Envelope, error)
 		Deserialize(data []byte) (Envelope, error)
+		SerializeContext(ctx context.Context, v any, opts ...SerializeOption) (Envelope, error)
+		DeserializeContext(ctx context.Context, data []byte) (Envelope, error)
+		SerializeBatch(vs []any, opts ...SerializeOption) ([]byte, error)
+		DeserializeBatch(data []byte) ([]Envelope, error)
+		DeserializeLazy(data []byte) (LazyEnvelope, error)
 		IsRegistered(v any) bool
+		RegisterAlias(alias string, v any) error
+		RegisterUpcaster(key string, version uint32, fn Upcaster) error
 		Build(key string) (any, error)
+		Freeze()
 	}
 
 	Serde interface {
@@ -26,15 +41,41 @@ type (
 	}
 
 	envelope struct {
-		key     string
-		payload any
-		data    []byte
+		key      string
+		payload  any
+		metadata map[string]string
+		data     []byte
 	}
 
 	registry struct {
-		serde         Serde
-		envelopeSerde Serde
-		factories     map[string]func() any
+		serde                 Serde
+		envelopeSerde         Serde
+		serdes                []Serde
+		compressor            Compressor
+		compressors           []Compressor
+		compressionThreshold  int
+		maxPayloadSize        int
+		validator             Validator
+		keyProvider           KeyProvider
+		subjectKeys           SubjectKeyStore
+		signer                Signer
+		observer              Observer
+		serializeMiddleware   []SerializeMiddleware
+		deserializeMiddleware []DeserializeMiddleware
+		serializer            SerializeHandler
+		deserializer          DeserializeHandler
+		types                 map[string]*registration
+		upcasters             map[string]map[uint32]Upcaster
+		mu                    sync.RWMutex
+		frozen                atomic.Bool
+	}
+
+	registration struct {
+		key     string
+		factory func() any
+		typ     reflect.Type
+		version uint32
+		serde   Serde
 	}
 )
 
@@ -42,9 +83,13 @@ type (
 //
 // The registry is used to register types that can be serialized as concrete types, then
 // deserialized back into their original types without knowing ahead of time what those types are.
+//
+// The registry is safe for concurrent use; types may be registered while other goroutines
+// are serializing and deserializing.
 func NewRegistry(opts ...RegistryOption) Registry {
 	r := &registry{
-		factories:     make(map[string]func() any),
+		types:         make(map[string]*registration),
+		upcasters:     make(map[string]map[uint32]Upcaster),
 		serde:         JsonSerde{},
 		envelopeSerde: ProtoSerde{},
 	}
@@ -53,6 +98,19 @@ func NewRegistry(opts ...RegistryOption) Registry {
 		opt(r)
 	}
 
+	// the provided serdes are always available to deserialize payloads
+	r.serdes = append(r.serdes, JsonSerde{}, ProtoSerde{})
+	// the provided compressors are always available to decompress payloads
+	r.compressors = append(r.compressors, GzipCompressor{}, ZstdCompressor{}, SnappyCompressor{})
+
+	// the observer is the outermost middleware so that it sees everything the registry does
+	if r.observer != nil {
+		r.serializeMiddleware = append([]SerializeMiddleware{r.observeSerialize}, r.serializeMiddleware...)
+		r.deserializeMiddleware = append([]DeserializeMiddleware{r.observeDeserialize}, r.deserializeMiddleware...)
+	}
+	r.serializer = chainSerialize(r.serialize, r.serializeMiddleware)
+	r.deserializer = chainDeserialize(r.deserialize, r.deserializeMiddleware)
+
 	return r
 }
 
@@ -61,6 +119,12 @@ func NewRegistry(opts ...RegistryOption) Registry {
 // The envelope key is the fully qualified type name of the type being registered,
 // or the key will be the result of calling the EnvelopeKey method on the type
 // being registered.
+//
+// The current version of the type is 1, or the result of calling the EnvelopeVersion
+// method on the type being registered.
+//
+// Any keys returned by an EnvelopeKeyAliases method on the type being registered
+// are registered as aliases of the envelope key.
 func (r *registry) Register(vs ...any) error {
 	for _, v := range vs {
 		key := getKey(v)
@@ -68,7 +132,7 @@ func (r *registry) Register(vs ...any) error {
 		if t.Kind() == reflect.Ptr {
 			t = t.Elem()
 		}
-		if err := r.register(key, func() any {
+		if err := r.register(key, getVersion(v), getAliases(v), func() any {
 			return reflect.New(t).Interface()
 		}); err != nil {
 			return err
@@ -78,6 +142,21 @@ func (r *registry) Register(vs ...any) error {
 	return nil
 }
 
+// RegisterWith registers a type with the registry using the provided options.
+//
+// The envelope key, version, and aliases are determined in the same way as Register.
+func (r *registry) RegisterWith(v any, opts ...TypeOption) error {
+	key := getKey(v)
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return r.register(key, getVersion(v), getAliases(v), func() any {
+		return reflect.New(t).Interface()
+	}, opts...)
+}
+
 // RegisterFactory registers one or more factory functions with the registry.
 //
 // The factory function should return a pointer to the type being registered.
@@ -98,7 +177,7 @@ func (r *registry) RegisterFactory(fns ...func() any) error {
 			return ErrFactoryDoesNotReturnPointer(key)
 		}
 
-		if err := r.register(key, fn); err != nil {
+		if err := r.register(key, getVersion(v), getAliases(v), fn); err != nil {
 			return err
 		}
 	}
@@ -110,32 +189,105 @@ func (r *registry) RegisterFactory(fns ...func() any) error {
 //
 // The value must be registered with the registry before it can be serialized,
 // otherwise calls will return an ErrUnregisteredKey error.
-func (r *registry) Serialize(v any) (Envelope, error) {
+//
+// Metadata may be sealed alongside the value by passing the WithMetadata option, and the
+// payload may be encrypted with the key of a subject by passing the WithSubject option.
+//
+// Values with a Validate() error method, and values of registries with a Validator, are
+// validated before they are serialized, and an ErrValidation error is returned when they
+// are invalid.
+//
+// Errors are returned wrapped in a SerdeError that describes the stage that failed.
+//
+// Any SerializeMiddleware the registry was created with is run around the serialization.
+func (r *registry) Serialize(v any, opts ...SerializeOption) (Envelope, error) {
+	return r.SerializeContext(context.Background(), v, opts...)
+}
+
+// SerializeContext serializes a value the same as Serialize, passing the context to
+// any SerializeMiddleware the registry was created with.
+func (r *registry) SerializeContext(ctx context.Context, v any, opts ...SerializeOption) (Envelope, error) {
 	key := getKey(v)
+	if reg, exists := r.lookup(key); exists {
+		key = reg.key
+	}
 
-	if _, exists := r.factories[key]; !exists {
-		return nil, ErrUnregisteredKey(key)
+	return r.serializer(ctx, key, v, opts...)
+}
+
+func (r *registry) serialize(_ context.Context, key string, v any, opts ...SerializeOption) (Envelope, error) {
+	cfg := newSerializeConfig(opts...)
+
+	reg, exists := r.lookup(key)
+	if !exists {
+		return nil, serdeError(OpSerialize, StageLookup, key, ErrUnregisteredKey(key))
+	}
+	// always seal the envelope using the canonical key
+	key = reg.key
+	// middleware may have changed the key to one registered for some other type
+	if elemType(v) != reg.typ {
+		return nil, serdeError(OpSerialize, StageLookup, key, ErrKeyTypeMismatch{Key: key, Type: fmt.Sprintf("%T", v)})
+	}
+	version := reg.version
+	serde := reg.serdeOr(r.serde)
+
+	if err := r.validate(key, v); err != nil {
+		return nil, serdeError(OpSerialize, StageValidation, key, err)
 	}
 
-	data, err := r.serde.Serialize(v)
+	data, err := serde.Serialize(v)
 	if err != nil {
-		return nil, err
+		return nil, serdeError(OpSerialize, StagePayload, key, err)
+	}
+
+	data, compression, err := r.compress(data)
+	if err != nil {
+		return nil, serdeError(OpSerialize, StageCompression, key, err)
+	}
+
+	data, err = r.encryptFor(key, cfg.subject, data)
+	if err != nil {
+		return nil, serdeError(OpSerialize, StageEncryption, key, err)
+	}
+
+	data, keyID, err := r.encrypt(key, data)
+	if err != nil {
+		return nil, serdeError(OpSerialize, StageEncryption, key, err)
 	}
 
 	msg := &EnvelopeMsg{
-		Key:     &key,
-		Payload: data,
+		Key:      &key,
+		Payload:  data,
+		Metadata: cfg.metadata,
+		Version:  &version,
+	}
+	if contentType := getContentType(serde); contentType != "" {
+		msg.ContentType = &contentType
+	}
+	if compression != "" {
+		msg.Compression = &compression
+	}
+	if keyID != "" {
+		msg.KeyId = &keyID
+	}
+	if cfg.subject != "" {
+		msg.Subject = &cfg.subject
+	}
+
+	if err = r.sign(msg); err != nil {
+		return nil, serdeError(OpSerialize, StageSignature, key, err)
 	}
 
 	data, err = r.envelopeSerde.Serialize(msg)
 	if err != nil {
-		return nil, err
+		return nil, serdeError(OpSerialize, StageEnvelope, key, err)
 	}
 
 	return &envelope{
-		key:     key,
-		payload: v,
-		data:    data,
+		key:      key,
+		payload:  v,
+		metadata: cfg.metadata,
+		data:     data,
 	}, nil
 }
 
@@ -143,55 +295,317 @@ func (r *registry) Serialize(v any) (Envelope, error) {
 //
 // The byte slice must have been serialized using the Serialize method of the registry,
 // otherwise calls will return an ErrUnregisteredKey error.
+//
+// Payloads serialized with an older version of the type are upcast to the current
+// version before they are deserialized.
+//
+// Envelopes sealed with an alias of a registered key are deserialized into the type
+// registered for that key.
+//
+// When the registry signs envelopes, the signature is verified before anything else
+// is done with the envelope, and an ErrInvalidSignature error is returned when the
+// signature is missing or invalid.
+//
+// An ErrSubjectForgotten error is returned when the payload was encrypted for a subject
+// whose key no longer exists.
+//
+// Payloads are validated after they are deserialized the same as values are before they
+// are serialized.
+//
+// Errors are returned wrapped in a SerdeError that describes the stage that failed.
+//
+// Any DeserializeMiddleware the registry was created with is run around the deserialization.
 func (r *registry) Deserialize(data []byte) (Envelope, error) {
-	msg := new(EnvelopeMsg)
-	if err := r.envelopeSerde.Deserialize(data, msg); err != nil {
-		return nil, err
-	}
+	return r.DeserializeContext(context.Background(), data)
+}
 
-	key := *msg.Key
-	fn, exists := r.factories[key]
-	if !exists {
-		return nil, ErrUnregisteredKey(key)
+// DeserializeContext deserializes a byte slice the same as Deserialize, passing the
+// context to any DeserializeMiddleware the registry was created with.
+func (r *registry) DeserializeContext(ctx context.Context, data []byte) (Envelope, error) {
+	return r.deserializer(ctx, data)
+}
+
+func (r *registry) deserialize(_ context.Context, data []byte) (Envelope, error) {
+	msg, err := r.open(data)
+	if err != nil {
+		return nil, err
 	}
 
-	v := fn()
-	if err := r.serde.Deserialize(msg.Payload, v); err != nil {
+	key, v, err := r.decode(msg)
+	if err != nil {
 		return nil, err
 	}
 
 	return &envelope{
-		key:     key,
-		payload: v,
-		data:    data,
+		key:      key,
+		payload:  v,
+		metadata: msg.GetMetadata(),
+		data:     data,
 	}, nil
 }
 
 // IsRegistered returns true if the type is registered with the registry.
 func (r *registry) IsRegistered(v any) bool {
-	_, exists := r.factories[getKey(v)]
+	_, exists := r.lookup(getKey(v))
 	return exists
 }
 
+// RegisterAlias registers an alias for the key of an already registered type.
+//
+// Envelopes sealed with the alias, such as envelopes sealed before a type was
+// renamed or moved, will be deserialized into the registered type. Serialize will
+// always seal envelopes using the key of the registered type.
+func (r *registry) RegisterAlias(alias string, v any) error {
+	key := getKey(v)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.frozen.Load() {
+		return ErrRegistryFrozen(alias)
+	}
+
+	reg, exists := r.types[key]
+	if !exists {
+		return ErrUnregisteredKey(key)
+	}
+
+	if _, exists = r.types[alias]; exists {
+		return ErrReregisteredKey(alias)
+	}
+
+	r.types[alias] = reg
+	return nil
+}
+
+// RegisterUpcaster registers a function that upcasts payloads of the given key
+// from the given version to the next version.
+//
+// Upcasters are chained together during deserialization, so an envelope sealed
+// at version 1 of a type currently at version 3 will be passed through the
+// upcasters registered for versions 1 and 2. Upcasters are always registered
+// using the key of the registered type and not any of its aliases.
+func (r *registry) RegisterUpcaster(key string, version uint32, fn Upcaster) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.frozen.Load() {
+		return ErrRegistryFrozen(key)
+	}
+
+	if _, exists := r.upcasters[key][version]; exists {
+		return ErrReregisteredUpcaster{Key: key, Version: version}
+	}
+
+	if r.upcasters[key] == nil {
+		r.upcasters[key] = make(map[uint32]Upcaster)
+	}
+	r.upcasters[key][version] = fn
+	return nil
+}
+
 // Build creates a new instance of a registered type.
 func (r *registry) Build(key string) (any, error) {
-	fn, exists := r.factories[key]
+	reg, exists := r.lookup(key)
 	if !exists {
 		return nil, ErrUnregisteredKey(key)
 	}
 
-	return fn(), nil
+	return reg.factory(), nil
 }
 
-func (r *registry) register(key string, fn func() any) error {
-	if _, exists := r.factories[key]; exists {
-		return ErrReregisteredKey(key)
+// Freeze prevents any further types from being registered with the registry.
+//
+// Calls to Register and RegisterFactory after the registry has been frozen will
+// return an ErrRegistryFrozen error. Lookups on a frozen registry no longer take
+// the registry lock.
+func (r *registry) Freeze() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.frozen.Store(true)
+}
+
+func (r *registry) register(key string, version uint32, aliases []string, fn func() any, opts ...TypeOption) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.frozen.Load() {
+		return ErrRegistryFrozen(key)
+	}
+
+	for _, k := range append([]string{key}, aliases...) {
+		if _, exists := r.types[k]; exists {
+			return ErrReregisteredKey(k)
+		}
+	}
+
+	reg := &registration{
+		key:     key,
+		factory: fn,
+		typ:     elemType(fn()),
+		version: version,
+	}
+	for _, opt := range opts {
+		opt(reg)
+	}
+
+	r.types[key] = reg
+	for _, alias := range aliases {
+		r.types[alias] = reg
+	}
+	return nil
+}
+
+// open deserializes the envelope and verifies its signature
+func (r *registry) open(data []byte) (*EnvelopeMsg, error) {
+	msg := new(EnvelopeMsg)
+	if err := r.envelopeSerde.Deserialize(data, msg); err != nil {
+		return nil, serdeError(OpDeserialize, StageEnvelope, "", err)
+	}
+
+	// envelopes come from outside the process and cannot be trusted
+	if msg.GetKey() == "" {
+		return nil, serdeError(OpDeserialize, StageEnvelope, "", ErrMissingKey{})
+	}
+	if !utf8.ValidString(msg.GetKey()) {
+		return nil, serdeError(OpDeserialize, StageEnvelope, "", ErrInvalidKey(msg.GetKey()))
+	}
+	if err := r.checkPayloadSize(msg.GetKey(), msg.GetPayload()); err != nil {
+		return nil, serdeError(OpDeserialize, StagePayload, msg.GetKey(), err)
+	}
+
+	if err := r.verify(msg); err != nil {
+		return nil, serdeError(OpDeserialize, StageSignature, msg.GetKey(), err)
+	}
+
+	return msg, nil
+}
+
+// decode deserializes the payload of the envelope into a new instance of the registered type
+func (r *registry) decode(msg *EnvelopeMsg) (string, any, error) {
+	key := msg.GetKey()
+	reg, exists := r.lookup(key)
+	if !exists {
+		return "", nil, serdeError(OpDeserialize, StageLookup, key, ErrUnregisteredKey(key))
+	}
+
+	// payloads are encrypted using the key they were sealed with
+	payload, err := r.decrypt(key, msg.GetKeyId(), msg.Payload)
+	if err != nil {
+		return "", nil, serdeError(OpDeserialize, StageEncryption, reg.key, err)
+	}
+
+	payload, err = r.decryptFor(key, msg.GetSubject(), payload)
+	if err != nil {
+		return "", nil, serdeError(OpDeserialize, StageEncryption, reg.key, err)
+	}
+	key = reg.key
+
+	payload, err = r.decompress(key, msg.GetCompression(), payload)
+	if err != nil {
+		return "", nil, serdeError(OpDeserialize, StageCompression, key, err)
+	}
+
+	payload, err = r.upcast(key, msg.GetVersion(), reg.version, payload)
+	if err != nil {
+		return "", nil, serdeError(OpDeserialize, StageVersion, key, err)
+	}
+
+	v := reg.factory()
+	serde, err := r.payloadSerde(reg, msg.GetContentType(), v)
+	if err != nil {
+		return "", nil, serdeError(OpDeserialize, StagePayload, key, err)
+	}
+
+	if err := serde.Deserialize(payload, v); err != nil {
+		return "", nil, serdeError(OpDeserialize, StagePayload, key, err)
+	}
+
+	if err := r.validate(key, v); err != nil {
+		return "", nil, serdeError(OpDeserialize, StageValidation, key, err)
 	}
 
-	r.factories[key] = fn
+	return key, v, nil
+}
+
+func (r *registry) checkPayloadSize(key string, payload []byte) error {
+	if r.maxPayloadSize > 0 && len(payload) > r.maxPayloadSize {
+		return ErrPayloadTooLarge{Key: key, Size: len(payload), Max: r.maxPayloadSize}
+	}
 	return nil
 }
 
+func (r *registry) lookup(key string) (*registration, bool) {
+	// the registrations can no longer change once frozen
+	if r.frozen.Load() {
+		reg, exists := r.types[key]
+		return reg, exists
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	reg, exists := r.types[key]
+	return reg, exists
+}
+
+// payloadSerde returns the serde used to deserialize the payload of a registered type.
+//
+// The content type sealed with the envelope is used to find the serde that was used
+// to serialize the payload when it differs from the serde that is currently in use.
+// The type serde is used for envelopes that were sealed without a content type.
+//
+// Serdes that cannot deserialize into v are skipped, so a content type sealed into an
+// untrusted envelope cannot select a serde that does not support the registered type.
+func (r *registry) payloadSerde(reg *registration, contentType string, v any) (Serde, error) {
+	serde := reg.serdeOr(r.serde)
+	if contentType == "" {
+		return serde, nil
+	}
+
+	matches := func(s Serde) bool {
+		return getContentType(s) == contentType && accepts(s, v)
+	}
+
+	if matches(serde) {
+		return serde, nil
+	}
+
+	if matches(r.serde) {
+		return r.serde, nil
+	}
+
+	for _, known := range r.serdes {
+		if matches(known) {
+			return known, nil
+		}
+	}
+
+	return nil, ErrContentTypeMismatch{Key: reg.key, ContentType: contentType}
+}
+
+func (r *registry) upcaster(key string, version uint32) (Upcaster, bool) {
+	if r.frozen.Load() {
+		fn, exists := r.upcasters[key][version]
+		return fn, exists
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	fn, exists := r.upcasters[key][version]
+	return fn, exists
+}
+
+func (r *registration) serdeOr(serde Serde) Serde {
+	if r.serde != nil {
+		return r.serde
+	}
+
+	return serde
+}
+
 func (e *envelope) Key() string {
 	return e.key
 }
@@ -200,10 +614,31 @@ func (e *envelope) Payload() any {
 	return e.payload
 }
 
+func (e *envelope) Metadata() map[string]string {
+	return e.metadata
+}
+
 func (e *envelope) Bytes() []byte {
 	return e.data
 }
 
+func getAliases(v any) []string {
+	if aliaser, ok := v.(interface{ EnvelopeKeyAliases() []string }); ok {
+		return aliaser.EnvelopeKeyAliases()
+	}
+
+	return nil
+}
+
+// elemType returns the type of v, or the type it points to when v is a pointer
+func elemType(v any) reflect.Type {
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
 func getKey(v any) string {
 	prefix := ""
 
@@ -13,3 +13,104 @@ func WithEnvelopeSerde(serde Serde) RegistryOption {
 		r.envelopeSerde = serde
 	}
 }
+
+// WithSerdes adds serdes that may be used to deserialize payloads.
+//
+// When the content type sealed with an envelope does not match the type or registry
+// Serde, the first of these serdes with a matching content type is used instead.
+// The JsonSerde and ProtoSerde are always available.
+func WithSerdes(serdes ...Serde) RegistryOption {
+	return func(r *registry) {
+		r.serdes = append(r.serdes, serdes...)
+	}
+}
+
+// WithCompression compresses payloads that are at least threshold bytes in size.
+//
+// The compressor is also made available to decompress payloads.
+func WithCompression(compressor Compressor, threshold int) RegistryOption {
+	return func(r *registry) {
+		r.compressor = compressor
+		r.compressionThreshold = threshold
+	}
+}
+
+// WithMaxPayloadSize limits the size of the payloads that will be deserialized.
+//
+// The payload is checked both as it was sealed in the envelope and as it is decompressed,
+// and an ErrPayloadTooLarge error is returned when either is larger than size bytes.
+// The provided compressors stop decompressing once the limit has been passed.
+// Uncompressed payloads of any size are deserialized by default, while compressed
+// payloads are limited to DefaultMaxDecompressedSize bytes once decompressed.
+func WithMaxPayloadSize(size int) RegistryOption {
+	return func(r *registry) {
+		r.maxPayloadSize = size
+	}
+}
+
+// WithValidator validates every payload that is serialized or deserialized.
+//
+// The validator is called after the Validate method of any payload that has one.
+func WithValidator(validator Validator) RegistryOption {
+	return func(r *registry) {
+		r.validator = validator
+	}
+}
+
+// WithCompressors adds compressors that may be used to decompress payloads.
+//
+// The GzipCompressor, ZstdCompressor, and SnappyCompressor are always available.
+func WithCompressors(compressors ...Compressor) RegistryOption {
+	return func(r *registry) {
+		r.compressors = append(r.compressors, compressors...)
+	}
+}
+
+// WithEncryption encrypts payloads using AES-GCM with keys from the KeyProvider.
+//
+// The ID of the key used to encrypt each payload is sealed into the envelope so that
+// payloads remain decryptable after the current key has been rotated.
+func WithEncryption(provider KeyProvider) RegistryOption {
+	return func(r *registry) {
+		r.keyProvider = provider
+	}
+}
+
+// WithSigning signs each envelope and verifies the signature of each envelope deserialized.
+func WithSigning(signer Signer) RegistryOption {
+	return func(r *registry) {
+		r.signer = signer
+	}
+}
+
+// WithSubjectKeys sets the store of subject keys used with the WithSubject option.
+func WithSubjectKeys(store SubjectKeyStore) RegistryOption {
+	return func(r *registry) {
+		r.subjectKeys = store
+	}
+}
+
+// WithSerializeMiddleware wraps Serialize with the middleware.
+//
+// The first middleware is the outermost and is the first to see each value.
+func WithSerializeMiddleware(mws ...SerializeMiddleware) RegistryOption {
+	return func(r *registry) {
+		r.serializeMiddleware = append(r.serializeMiddleware, mws...)
+	}
+}
+
+// WithDeserializeMiddleware wraps Deserialize with the middleware.
+//
+// The first middleware is the outermost and is the first to see each byte slice.
+func WithDeserializeMiddleware(mws ...DeserializeMiddleware) RegistryOption {
+	return func(r *registry) {
+		r.deserializeMiddleware = append(r.deserializeMiddleware, mws...)
+	}
+}
+
+// WithObserver reports every serialize and deserialize operation to the observer.
+func WithObserver(observer Observer) RegistryOption {
+	return func(r *registry) {
+		r.observer = observer
+	}
+}
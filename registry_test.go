@@ -3,9 +3,15 @@ package envelope_test
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
 	"github.com/stackus/envelope"
 )
 
@@ -44,6 +50,30 @@ func (TestPrefix) EnvelopeKeyPrefix() string {
 	return "prefix."
 }
 
+type LegacyTest struct {
+	Test string
+}
+
+type RenamedTest struct {
+	Test string
+}
+
+func (LegacyTest) EnvelopeKey() string {
+	return "legacy"
+}
+
+func (RenamedTest) EnvelopeKeyAliases() []string {
+	return []string{"legacy", "older.legacy"}
+}
+
+type NamedTest struct {
+	Name string
+}
+
+func (t NamedTest) EnvelopeKey() string {
+	return t.Name
+}
+
 type brokenSerializer struct{}
 
 func (brokenSerializer) Serialize(any) ([]byte, error) {
@@ -655,3 +685,733 @@ func TestRegistry_IsRegistered(t *testing.T) {
 		})
 	}
 }
+
+func TestRegistry_Concurrent(t *testing.T) {
+	const workers = 8
+	const keys = 50
+
+	r := envelope.NewRegistry()
+	if err := r.Register(&Test{}); err != nil {
+		t.Fatalf("Registry.Register() error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < keys; i++ {
+				name := fmt.Sprintf("named.%d.%d", w, i)
+				if err := r.RegisterFactory(func() any { return &NamedTest{Name: name} }); err != nil {
+					t.Errorf("Registry.RegisterFactory() error = %v", err)
+				}
+			}
+		}(w)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < keys; i++ {
+				env, err := r.Serialize(&Test{Test: "testing"})
+				if err != nil {
+					t.Errorf("Registry.Serialize() error = %v", err)
+					continue
+				}
+				if _, err = r.Deserialize(env.Bytes()); err != nil {
+					t.Errorf("Registry.Deserialize() error = %v", err)
+				}
+				_ = r.IsRegistered(&NamedTest{Name: "named.0.0"})
+				_, _ = r.Build("named.0.0")
+			}
+		}()
+	}
+	wg.Wait()
+
+	for w := 0; w < workers; w++ {
+		for i := 0; i < keys; i++ {
+			if !r.IsRegistered(&NamedTest{Name: fmt.Sprintf("named.%d.%d", w, i)}) {
+				t.Errorf("Registry.IsRegistered() = false, want true for named.%d.%d", w, i)
+			}
+		}
+	}
+}
+
+func TestRegistry_Freeze(t *testing.T) {
+	tests := map[string]struct {
+		register func(r envelope.Registry) error
+		wantErr  bool
+	}{
+		"register": {
+			register: func(r envelope.Registry) error {
+				return r.Register(&KeyedTest{})
+			},
+			wantErr: true,
+		},
+		"register factory": {
+			register: func(r envelope.Registry) error {
+				return r.RegisterFactory(func() any {
+					return &KeyedTest{}
+				})
+			},
+			wantErr: true,
+		},
+		"nothing": {
+			register: func(r envelope.Registry) error {
+				return r.Register()
+			},
+			wantErr: false,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := envelope.NewRegistry()
+			_ = r.Register(&Test{})
+			r.Freeze()
+
+			err := tt.register(r)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Registry.Register() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				var frozenErr envelope.ErrRegistryFrozen
+				if !errors.As(err, &frozenErr) {
+					t.Errorf("Registry.Register() error = %T, want %T", err, frozenErr)
+				}
+			}
+
+			env, err := r.Serialize(&Test{Test: "testing"})
+			if err != nil {
+				t.Fatalf("Registry.Serialize() error = %v", err)
+			}
+			if _, err = r.Deserialize(env.Bytes()); err != nil {
+				t.Errorf("Registry.Deserialize() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestRegistry_FreezeConcurrent(t *testing.T) {
+	r := envelope.NewRegistry()
+	_ = r.Register(&Test{})
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				env, err := r.Serialize(&Test{Test: "testing"})
+				if err != nil {
+					t.Errorf("Registry.Serialize() error = %v", err)
+					continue
+				}
+				if _, err = r.Deserialize(env.Bytes()); err != nil {
+					t.Errorf("Registry.Deserialize() error = %v", err)
+				}
+			}
+		}()
+	}
+	r.Freeze()
+	wg.Wait()
+}
+
+func TestRegistry_Metadata(t *testing.T) {
+	tests := map[string]struct {
+		options  []envelope.RegistryOption
+		metadata []map[string]string
+		want     map[string]string
+	}{
+		"none": {
+			want: map[string]string{},
+		},
+		"single": {
+			metadata: []map[string]string{
+				{"correlation_id": "abc", "tenant_id": "123"},
+			},
+			want: map[string]string{"correlation_id": "abc", "tenant_id": "123"},
+		},
+		"merged": {
+			metadata: []map[string]string{
+				{"correlation_id": "abc", "tenant_id": "123"},
+				{"tenant_id": "456"},
+			},
+			want: map[string]string{"correlation_id": "abc", "tenant_id": "456"},
+		},
+		"set serdes": {
+			options: []envelope.RegistryOption{
+				envelope.WithEnvelopeSerde(envelope.JsonSerde{}),
+			},
+			metadata: []map[string]string{
+				{"correlation_id": "abc"},
+			},
+			want: map[string]string{"correlation_id": "abc"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := envelope.NewRegistry(tt.options...)
+			_ = r.Register(&Test{})
+
+			var opts []envelope.SerializeOption
+			for _, md := range tt.metadata {
+				opts = append(opts, envelope.WithMetadata(md))
+			}
+
+			env, err := r.Serialize(&Test{Test: "testing"}, opts...)
+			if err != nil {
+				t.Fatalf("Registry.Serialize() error = %v", err)
+			}
+			if len(env.Metadata()) != len(tt.want) {
+				t.Errorf("Registry.Serialize() metadata = %v, want %v", env.Metadata(), tt.want)
+			}
+
+			received, err := r.Deserialize(env.Bytes())
+			if err != nil {
+				t.Fatalf("Registry.Deserialize() error = %v", err)
+			}
+			if len(received.Metadata()) != len(tt.want) {
+				t.Errorf("Registry.Deserialize() metadata = %v, want %v", received.Metadata(), tt.want)
+			}
+			for k, v := range tt.want {
+				if got := received.Metadata()[k]; got != v {
+					t.Errorf("Registry.Deserialize() metadata[%q] = %q, want %q", k, got, v)
+				}
+			}
+		})
+	}
+}
+
+func TestRegistry_RegisterAlias(t *testing.T) {
+	type args struct {
+		alias string
+		v     any
+	}
+	tests := map[string]struct {
+		registry envelope.Registry
+		args     args
+		wantErr  bool
+	}{
+		"success": {
+			registry: func() envelope.Registry {
+				r := envelope.NewRegistry()
+				_ = r.Register(&Test{})
+				return r
+			}(),
+			args: args{
+				alias: "legacy",
+				v:     &Test{},
+			},
+			wantErr: false,
+		},
+		"not registered": {
+			registry: func() envelope.Registry {
+				r := envelope.NewRegistry()
+				return r
+			}(),
+			args: args{
+				alias: "legacy",
+				v:     &Test{},
+			},
+			wantErr: true,
+		},
+		"alias is registered key": {
+			registry: func() envelope.Registry {
+				r := envelope.NewRegistry()
+				_ = r.Register(&Test{}, &KeyedTest{})
+				return r
+			}(),
+			args: args{
+				alias: "test",
+				v:     &Test{},
+			},
+			wantErr: true,
+		},
+		"alias is registered alias": {
+			registry: func() envelope.Registry {
+				r := envelope.NewRegistry()
+				_ = r.Register(&Test{}, &RenamedTest{})
+				return r
+			}(),
+			args: args{
+				alias: "legacy",
+				v:     &Test{},
+			},
+			wantErr: true,
+		},
+		"frozen": {
+			registry: func() envelope.Registry {
+				r := envelope.NewRegistry()
+				_ = r.Register(&Test{})
+				r.Freeze()
+				return r
+			}(),
+			args: args{
+				alias: "legacy",
+				v:     &Test{},
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := tt.registry.RegisterAlias(tt.args.alias, tt.args.v); (err != nil) != tt.wantErr {
+				t.Errorf("Registry.RegisterAlias() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRegistry_DeserializeAlias(t *testing.T) {
+	tests := map[string]struct {
+		registry envelope.Registry
+		wantKey  string
+	}{
+		"registered alias": {
+			registry: func() envelope.Registry {
+				r := envelope.NewRegistry()
+				_ = r.Register(&Test{})
+				_ = r.RegisterAlias("legacy", &Test{})
+				return r
+			}(),
+			wantKey: "envelope_test.Test",
+		},
+		"alias method": {
+			registry: func() envelope.Registry {
+				r := envelope.NewRegistry()
+				_ = r.Register(&RenamedTest{})
+				return r
+			}(),
+			wantKey: "envelope_test.RenamedTest",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			producer := envelope.NewRegistry()
+			_ = producer.Register(&LegacyTest{})
+			env, err := producer.Serialize(&LegacyTest{Test: "testing"})
+			if err != nil {
+				t.Fatalf("Registry.Serialize() error = %v", err)
+			}
+
+			received, err := tt.registry.Deserialize(env.Bytes())
+			if err != nil {
+				t.Fatalf("Registry.Deserialize() error = %v", err)
+			}
+			if received.Key() != tt.wantKey {
+				t.Errorf("Registry.Deserialize() key = %v, want %v", received.Key(), tt.wantKey)
+			}
+
+			// re-serializing seals the envelope with the canonical key
+			resealed, err := tt.registry.Serialize(received.Payload())
+			if err != nil {
+				t.Fatalf("Registry.Serialize() error = %v", err)
+			}
+			if resealed.Key() != tt.wantKey {
+				t.Errorf("Registry.Serialize() key = %v, want %v", resealed.Key(), tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestRegistry_RegisterWith(t *testing.T) {
+	type args struct {
+		v    any
+		opts []envelope.TypeOption
+	}
+	tests := map[string]struct {
+		registry envelope.Registry
+		args     args
+		wantErr  bool
+	}{
+		"success": {
+			registry: envelope.NewRegistry(),
+			args: args{
+				v: &Test{},
+			},
+			wantErr: false,
+		},
+		"with serde": {
+			registry: envelope.NewRegistry(),
+			args: args{
+				v:    &wrapperspb.StringValue{},
+				opts: []envelope.TypeOption{envelope.WithTypeSerde(envelope.ProtoSerde{})},
+			},
+			wantErr: false,
+		},
+		"multiple same": {
+			registry: func() envelope.Registry {
+				r := envelope.NewRegistry()
+				_ = r.Register(&Test{})
+				return r
+			}(),
+			args: args{
+				v: Test{},
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := tt.registry.RegisterWith(tt.args.v, tt.args.opts...); (err != nil) != tt.wantErr {
+				t.Errorf("Registry.RegisterWith() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRegistry_TypeSerde(t *testing.T) {
+	tests := map[string]struct {
+		producer envelope.Registry
+		consumer envelope.Registry
+		data     any
+	}{
+		"mixed serdes": {
+			producer: func() envelope.Registry {
+				r := envelope.NewRegistry()
+				_ = r.Register(&Test{})
+				_ = r.RegisterWith(&wrapperspb.StringValue{}, envelope.WithTypeSerde(envelope.ProtoSerde{}))
+				return r
+			}(),
+			consumer: func() envelope.Registry {
+				r := envelope.NewRegistry()
+				_ = r.Register(&Test{})
+				_ = r.RegisterWith(&wrapperspb.StringValue{}, envelope.WithTypeSerde(envelope.ProtoSerde{}))
+				return r
+			}(),
+			data: wrapperspb.String("testing"),
+		},
+		"type serde changed": {
+			producer: func() envelope.Registry {
+				r := envelope.NewRegistry()
+				_ = r.RegisterWith(&wrapperspb.StringValue{}, envelope.WithTypeSerde(envelope.ProtoSerde{}))
+				return r
+			}(),
+			consumer: func() envelope.Registry {
+				r := envelope.NewRegistry()
+				_ = r.Register(&wrapperspb.StringValue{})
+				return r
+			}(),
+			data: wrapperspb.String("testing"),
+		},
+		"registry serde changed": {
+			producer: func() envelope.Registry {
+				r := envelope.NewRegistry()
+				_ = r.Register(&Test{})
+				return r
+			}(),
+			consumer: func() envelope.Registry {
+				r := envelope.NewRegistry(envelope.WithSerde(brokenDeserializer{}))
+				_ = r.RegisterWith(&Test{}, envelope.WithTypeSerde(brokenDeserializer{}))
+				return r
+			}(),
+			data: &Test{Test: "testing"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			env, err := tt.producer.Serialize(tt.data)
+			if err != nil {
+				t.Fatalf("Registry.Serialize() error = %v", err)
+			}
+			received, err := tt.consumer.Deserialize(env.Bytes())
+			if err != nil {
+				t.Fatalf("Registry.Deserialize() error = %v", err)
+			}
+			if reflect.TypeOf(received.Payload()) != reflect.TypeOf(tt.data) {
+				t.Errorf("Registry.Deserialize() = %T, want %T", received.Payload(), tt.data)
+			}
+			if received.Payload().(fmt.Stringer).String() == "" {
+				t.Errorf("Registry.Deserialize() payload is empty")
+			}
+		})
+	}
+}
+
+// rawKeySerde opens envelopes that contain nothing but the key, which need not be valid UTF-8
+type rawKeySerde struct{}
+
+func (rawKeySerde) Serialize(v any) ([]byte, error) {
+	return []byte(v.(*envelope.EnvelopeMsg).GetKey()), nil
+}
+
+func (rawKeySerde) Deserialize(data []byte, v any) error {
+	v.(*envelope.EnvelopeMsg).Key = proto.String(string(data))
+	return nil
+}
+
+func TestRegistry_DeserializeMalformed(t *testing.T) {
+	marshal := func(msg *envelope.EnvelopeMsg) []byte {
+		data, err := proto.Marshal(msg)
+		if err != nil {
+			t.Fatalf("proto.Marshal() error = %v", err)
+		}
+		return data
+	}
+	serialize := func(v any, opts ...envelope.RegistryOption) []byte {
+		r := envelope.NewRegistry(opts...)
+		_ = r.Register(&Test{})
+		env, err := r.Serialize(v)
+		if err != nil {
+			t.Fatalf("Registry.Serialize() error = %v", err)
+		}
+		return env.Bytes()
+	}
+
+	tests := map[string]struct {
+		opts    []envelope.RegistryOption
+		data    []byte
+		wantErr error
+	}{
+		"missing key": {
+			data:    marshal(&envelope.EnvelopeMsg{Payload: []byte(`{}`)}),
+			wantErr: envelope.ErrMissingKey{},
+		},
+		"empty key": {
+			data:    marshal(&envelope.EnvelopeMsg{Key: proto.String(""), Payload: []byte(`{}`)}),
+			wantErr: envelope.ErrMissingKey{},
+		},
+		"invalid key": {
+			opts:    []envelope.RegistryOption{envelope.WithEnvelopeSerde(rawKeySerde{})},
+			data:    []byte{'k', 0xff},
+			wantErr: envelope.ErrInvalidKey([]byte{'k', 0xff}),
+		},
+		"within limit": {
+			opts: []envelope.RegistryOption{envelope.WithMaxPayloadSize(4096)},
+			data: serialize(&Test{Test: "testing"}),
+		},
+		"oversized payload": {
+			opts:    []envelope.RegistryOption{envelope.WithMaxPayloadSize(4096)},
+			data:    serialize(largeTest),
+			wantErr: envelope.ErrPayloadTooLarge{Key: "envelope_test.Test", Size: len(largeTest.Test) + 11, Max: 4096},
+		},
+		"oversized gzip payload": {
+			opts:    []envelope.RegistryOption{envelope.WithMaxPayloadSize(4096)},
+			data:    serialize(largeTest, envelope.WithCompression(envelope.GzipCompressor{}, 0)),
+			wantErr: envelope.ErrPayloadTooLarge{Key: "envelope_test.Test", Size: 4097, Max: 4096},
+		},
+		"oversized zstd payload": {
+			opts:    []envelope.RegistryOption{envelope.WithMaxPayloadSize(4096)},
+			data:    serialize(largeTest, envelope.WithCompression(envelope.ZstdCompressor{}, 0)),
+			wantErr: envelope.ErrPayloadTooLarge{Key: "envelope_test.Test", Size: 4097, Max: 4096},
+		},
+		"oversized snappy payload": {
+			opts:    []envelope.RegistryOption{envelope.WithMaxPayloadSize(4096)},
+			data:    serialize(largeTest, envelope.WithCompression(envelope.SnappyCompressor{}, 0)),
+			wantErr: envelope.ErrPayloadTooLarge{Key: "envelope_test.Test", Size: len(largeTest.Test) + 11, Max: 4096},
+		},
+		"compressed within limit": {
+			opts: []envelope.RegistryOption{envelope.WithMaxPayloadSize(len(largeTest.Test) + 11)},
+			data: serialize(largeTest, envelope.WithCompression(envelope.ZstdCompressor{}, 0)),
+		},
+		"mismatched content type": {
+			data: marshal(&envelope.EnvelopeMsg{
+				Key:         proto.String("envelope_test.Test"),
+				Payload:     []byte(`{}`),
+				ContentType: proto.String("application/protobuf"),
+			}),
+			wantErr: envelope.ErrContentTypeMismatch{Key: "envelope_test.Test", ContentType: "application/protobuf"},
+		},
+		"old version null payload": {
+			data: marshal(&envelope.EnvelopeMsg{
+				Key:     proto.String("user"),
+				Payload: []byte(`null`),
+				Version: proto.Uint32(1),
+			}),
+			wantErr: envelope.ErrNotJSONObject{},
+		},
+		"old version array payload": {
+			data: marshal(&envelope.EnvelopeMsg{
+				Key:     proto.String("user"),
+				Payload: []byte(`[]`),
+				Version: proto.Uint32(1),
+			}),
+			wantErr: envelope.ErrNotJSONObject{},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := envelope.NewRegistry(tt.opts...)
+			_ = r.Register(&Test{}, &UserV2{})
+			_ = r.RegisterUpcaster("user", 1, userV1ToV2)
+
+			_, err := r.Deserialize(tt.data)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("Registry.Deserialize() error = %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Registry.Deserialize() error = %v, want %v", err, tt.wantErr)
+			}
+
+			lazy, err := r.DeserializeLazy(tt.data)
+			if err == nil {
+				_, err = lazy.Decode()
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Registry.DeserializeLazy() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func FuzzDeserialize(f *testing.F) {
+	kp := envelope.NewMemoryKeyProvider()
+	_ = kp.AddKey("one", make([]byte, 32))
+	keys := map[string][]byte{"one": []byte("secret")}
+
+	producers := [][]envelope.RegistryOption{
+		{},
+		{envelope.WithCompression(envelope.GzipCompressor{}, 0)},
+		{envelope.WithCompression(envelope.SnappyCompressor{}, 0)},
+		{envelope.WithEncryption(kp)},
+		{envelope.WithSigning(envelope.HMACSigner{KeyID: "one", Keys: keys})},
+	}
+	for _, opts := range producers {
+		r := envelope.NewRegistry(opts...)
+		_ = r.Register(&Test{}, &KeyedTest{}, &RenamedTest{})
+		for _, v := range []any{&Test{Test: "testing"}, &KeyedTest{Test: "testing"}, &LegacyTest{}} {
+			if env, err := r.Serialize(v, envelope.WithMetadata(map[string]string{"tenant_id": "123"})); err == nil {
+				f.Add(env.Bytes())
+			}
+		}
+	}
+	bomb := &Test{Test: strings.Repeat("0", 1<<20)}
+	for _, c := range []envelope.Compressor{envelope.GzipCompressor{}, envelope.ZstdCompressor{}, envelope.SnappyCompressor{}} {
+		r := envelope.NewRegistry(envelope.WithCompression(c, 0))
+		_ = r.Register(&Test{})
+		if env, err := r.Serialize(bomb); err == nil {
+			f.Add(env.Bytes())
+		}
+	}
+	for _, msg := range []*envelope.EnvelopeMsg{
+		{Key: proto.String("envelope_test.Test"), Payload: []byte(`{}`), ContentType: proto.String("application/protobuf")},
+		{Key: proto.String("envelope_test.Test"), Payload: []byte(`{}`), ContentType: proto.String("application/x-unknown")},
+		{Key: proto.String("user"), Payload: []byte(`null`), Version: proto.Uint32(1)},
+		{Key: proto.String("user"), Payload: []byte(`[]`), Version: proto.Uint32(1)},
+		{Key: proto.String("user"), Payload: []byte(`{"Name":1}`), Version: proto.Uint32(1)},
+	} {
+		if data, err := proto.Marshal(msg); err == nil {
+			f.Add(data)
+		}
+	}
+	f.Add([]byte{})
+	f.Add([]byte{0x12, 0x01, 'p'})
+	f.Add([]byte{0x0a, 0x00})
+	f.Add([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+
+	consumers := []envelope.Registry{
+		envelope.NewRegistry(envelope.WithEncryption(kp)),
+		envelope.NewRegistry(envelope.WithMaxPayloadSize(64)),
+		envelope.NewRegistry(envelope.WithSigning(envelope.HMACSigner{KeyID: "one", Keys: keys})),
+	}
+	for _, r := range consumers {
+		_ = r.Register(&Test{}, &KeyedTest{}, &RenamedTest{}, &UserV2{})
+		_ = r.RegisterUpcaster("user", 1, userV1ToV2)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, r := range consumers {
+			env, err := r.Deserialize(data)
+			if err == nil && (env.Key() == "" || env.Payload() == nil) {
+				t.Errorf("Registry.Deserialize() = %q %v, want a key and payload", env.Key(), env.Payload())
+			}
+
+			if lazy, err := r.DeserializeLazy(data); err == nil {
+				_, _ = lazy.Decode()
+			}
+		}
+		_, _, _ = envelope.PeekHeader(data)
+	})
+}
+
+func TestRegistry_DecompressionBomb(t *testing.T) {
+	// the limit is larger than any of the compressed payloads, but much smaller than the payload
+	const limit = 4 << 20
+	bomb := &Test{Test: strings.Repeat("0", 64<<20)}
+
+	tests := map[string]struct {
+		compressor envelope.Compressor
+		wantSize   int
+	}{
+		// decompression is stopped one byte past the limit
+		"gzip": {
+			compressor: envelope.GzipCompressor{},
+			wantSize:   limit + 1,
+		},
+		"zstd": {
+			compressor: envelope.ZstdCompressor{},
+			wantSize:   limit + 1,
+		},
+		// the decoded length is read from the header without decompressing anything
+		"snappy": {
+			compressor: envelope.SnappyCompressor{},
+			wantSize:   len(bomb.Test) + 11,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			producer := envelope.NewRegistry(envelope.WithCompression(tt.compressor, 0))
+			_ = producer.Register(&Test{})
+			env, err := producer.Serialize(bomb)
+			if err != nil {
+				t.Fatalf("Registry.Serialize() error = %v", err)
+			}
+			consumer := envelope.NewRegistry(envelope.WithMaxPayloadSize(limit))
+			_ = consumer.Register(&Test{})
+
+			_, err = consumer.Deserialize(env.Bytes())
+			wantErr := envelope.ErrPayloadTooLarge{Key: "envelope_test.Test", Size: tt.wantSize, Max: limit}
+			if !errors.Is(err, wantErr) {
+				t.Errorf("Registry.Deserialize() error = %v, want %v", err, wantErr)
+			}
+		})
+	}
+}
+
+func TestRegistry_DefaultDecompressionLimit(t *testing.T) {
+	// one byte larger than the limit once the payload has been serialized
+	bomb := &Test{Test: strings.Repeat("0", envelope.DefaultMaxDecompressedSize-10)}
+
+	producer := envelope.NewRegistry(envelope.WithCompression(envelope.ZstdCompressor{}, 0))
+	_ = producer.Register(&Test{})
+	env, err := producer.Serialize(bomb)
+	if err != nil {
+		t.Fatalf("Registry.Serialize() error = %v", err)
+	}
+
+	tests := map[string]struct {
+		opts    []envelope.RegistryOption
+		wantErr error
+	}{
+		"default": {
+			wantErr: envelope.ErrPayloadTooLarge{
+				Key:  "envelope_test.Test",
+				Size: envelope.DefaultMaxDecompressedSize + 1,
+				Max:  envelope.DefaultMaxDecompressedSize,
+			},
+		},
+		"max payload size": {
+			opts: []envelope.RegistryOption{envelope.WithMaxPayloadSize(envelope.DefaultMaxDecompressedSize + 1)},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			consumer := envelope.NewRegistry(tt.opts...)
+			_ = consumer.Register(&Test{})
+
+			_, err := consumer.Deserialize(env.Bytes())
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Registry.Deserialize() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,7 +1,13 @@
 package envelope
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
 
 	"google.golang.org/protobuf/proto"
 )
@@ -9,14 +15,64 @@ import (
 // JsonSerde is a Serde implementation for JSON
 //
 // It uses the encoding/json package to serialize and deserialize data.
-type JsonSerde struct{}
+//
+// Strict decoding may be enabled for a registry with WithSerde, or for a single type
+// with WithTypeSerde, by setting DisallowUnknownFields.
+type JsonSerde struct {
+	// DisallowUnknownFields returns an ErrUnknownField error when a payload contains
+	// a field that the value does not have
+	DisallowUnknownFields bool
+	// UseNumber decodes numbers into interface values as a json.Number instead of a float64
+	UseNumber bool
+}
+
+func (s JsonSerde) ContentType() string {
+	return "application/json"
+}
 
 func (s JsonSerde) Serialize(v any) ([]byte, error) {
 	return json.Marshal(v)
 }
 
 func (s JsonSerde) Deserialize(data []byte, v any) error {
-	return json.Unmarshal(data, v)
+	if !s.DisallowUnknownFields && !s.UseNumber {
+		return json.Unmarshal(data, v)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if s.DisallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if s.UseNumber {
+		dec.UseNumber()
+	}
+
+	if err := dec.Decode(v); err != nil {
+		// an empty payload is malformed; io.EOF would be mistaken for the end of a stream
+		if errors.Is(err, io.EOF) {
+			return io.ErrUnexpectedEOF
+		}
+		return unknownField(err)
+	}
+
+	// match json.Unmarshal, which rejects anything after the value
+	if rest := bytes.TrimSpace(data[dec.InputOffset():]); len(rest) != 0 {
+		return ErrTrailingData(len(rest))
+	}
+
+	return nil
+}
+
+// unknownField returns an ErrUnknownField error for the unknown field errors of the json package
+func unknownField(err error) error {
+	name, found := strings.CutPrefix(err.Error(), "json: unknown field ")
+	if !found {
+		return err
+	}
+	if field, err := strconv.Unquote(name); err == nil {
+		name = field
+	}
+	return ErrUnknownField(name)
 }
 
 // ProtoSerde is a Serde implementation for Protocol Buffers
@@ -24,10 +80,46 @@ func (s JsonSerde) Deserialize(data []byte, v any) error {
 // It uses the google.golang.org/protobuf/proto package to serialize and deserialize data.
 type ProtoSerde struct{}
 
+func (s ProtoSerde) ContentType() string {
+	return "application/protobuf"
+}
+
+// Accepts reports whether v is a protobuf message
+func (s ProtoSerde) Accepts(v any) bool {
+	_, ok := v.(proto.Message)
+	return ok
+}
+
 func (s ProtoSerde) Serialize(v any) ([]byte, error) {
-	return proto.Marshal(v.(proto.Message))
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, ErrNotProtoMessage(fmt.Sprintf("%T", v))
+	}
+	return proto.Marshal(msg)
 }
 
 func (s ProtoSerde) Deserialize(data []byte, v any) error {
-	return proto.Unmarshal(data, v.(proto.Message))
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return ErrNotProtoMessage(fmt.Sprintf("%T", v))
+	}
+	return proto.Unmarshal(data, msg)
+}
+
+func getContentType(serde Serde) string {
+	if typer, ok := serde.(interface{ ContentType() string }); ok {
+		return typer.ContentType()
+	}
+
+	return ""
+}
+
+// accepts reports whether the serde can serialize and deserialize v; serdes without an
+// Accepts(any) bool method are assumed to accept any value
+func accepts(serde Serde, v any) bool {
+	if accepter, ok := serde.(interface{ Accepts(any) bool }); ok {
+		return accepter.Accepts(v)
+	}
+
+	return true
 }
@@ -0,0 +1,322 @@
+package envelope_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/stackus/envelope"
+)
+
+type contentTypeSerde struct {
+	contentType string
+}
+
+func (s contentTypeSerde) ContentType() string {
+	return s.contentType
+}
+
+func (contentTypeSerde) Serialize(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (contentTypeSerde) Deserialize(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+func TestRegistry_ContentType(t *testing.T) {
+	tests := map[string]struct {
+		producer []envelope.RegistryOption
+		consumer []envelope.RegistryOption
+		wantErr  error
+	}{
+		"same serde": {
+			producer: []envelope.RegistryOption{envelope.WithSerde(contentTypeSerde{"application/x-test"})},
+			consumer: []envelope.RegistryOption{envelope.WithSerde(contentTypeSerde{"application/x-test"})},
+		},
+		"provided serde": {
+			producer: []envelope.RegistryOption{envelope.WithSerde(envelope.JsonSerde{})},
+			consumer: []envelope.RegistryOption{envelope.WithSerde(contentTypeSerde{"application/x-test"})},
+		},
+		"added serde": {
+			producer: []envelope.RegistryOption{envelope.WithSerde(contentTypeSerde{"application/x-test"})},
+			consumer: []envelope.RegistryOption{envelope.WithSerdes(contentTypeSerde{"application/x-test"})},
+		},
+		"no content type": {
+			producer: []envelope.RegistryOption{envelope.WithSerde(brokenDeserializer{})},
+			consumer: []envelope.RegistryOption{},
+		},
+		"unsupported type": {
+			producer: []envelope.RegistryOption{envelope.WithSerde(contentTypeSerde{"application/protobuf"})},
+			consumer: []envelope.RegistryOption{},
+			wantErr: envelope.ErrContentTypeMismatch{
+				Key:         "envelope_test.Test",
+				ContentType: "application/protobuf",
+			},
+		},
+		"mismatch": {
+			producer: []envelope.RegistryOption{envelope.WithSerde(contentTypeSerde{"application/x-test"})},
+			consumer: []envelope.RegistryOption{},
+			wantErr: envelope.ErrContentTypeMismatch{
+				Key:         "envelope_test.Test",
+				ContentType: "application/x-test",
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			producer := envelope.NewRegistry(tt.producer...)
+			_ = producer.Register(&Test{})
+			consumer := envelope.NewRegistry(tt.consumer...)
+			_ = consumer.Register(&Test{})
+
+			env, err := producer.Serialize(&Test{Test: "testing"})
+			if err != nil {
+				t.Fatalf("Registry.Serialize() error = %v", err)
+			}
+
+			received, err := consumer.Deserialize(env.Bytes())
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Registry.Deserialize() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Registry.Deserialize() error = %v", err)
+			}
+			if got := received.Payload().(*Test).Test; got != "testing" {
+				t.Errorf("Registry.Deserialize() = %v, want %v", got, "testing")
+			}
+		})
+	}
+}
+
+func TestProtoSerde(t *testing.T) {
+	tests := map[string]struct {
+		v       any
+		wantErr error
+	}{
+		"message": {
+			v: &envelope.EnvelopeMsg{Key: proto.String("testing")},
+		},
+		"not a message": {
+			v:       &Test{Test: "testing"},
+			wantErr: envelope.ErrNotProtoMessage("*envelope_test.Test"),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			serde := envelope.ProtoSerde{}
+
+			data, err := serde.Serialize(tt.v)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ProtoSerde.Serialize() error = %v, want %v", err, tt.wantErr)
+			}
+			if err = serde.Deserialize(data, tt.v); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ProtoSerde.Deserialize() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// errSyntax stands in for the *json.SyntaxError errors returned by json.Unmarshal
+var errSyntax = errors.New("syntax error")
+
+func TestJsonSerde_Deserialize(t *testing.T) {
+	type nested struct {
+		Inner struct {
+			Name string
+		}
+	}
+
+	tests := map[string]struct {
+		serde   envelope.JsonSerde
+		data    string
+		v       any
+		want    any
+		wantErr error
+	}{
+		"lenient": {
+			data: `{"Test":"testing","Extra":true}`,
+			v:    &Test{},
+			want: &Test{Test: "testing"},
+		},
+		"strict": {
+			serde: envelope.JsonSerde{DisallowUnknownFields: true},
+			data:  `{"Test":"testing"}`,
+			v:     &Test{},
+			want:  &Test{Test: "testing"},
+		},
+		"strict unknown field": {
+			serde:   envelope.JsonSerde{DisallowUnknownFields: true},
+			data:    `{"Test":"testing","Extra":true}`,
+			v:       &Test{},
+			wantErr: envelope.ErrUnknownField("Extra"),
+		},
+		"strict nested unknown field": {
+			serde:   envelope.JsonSerde{DisallowUnknownFields: true},
+			data:    `{"Inner":{"Name":"testing","Extra":true}}`,
+			v:       &nested{},
+			wantErr: envelope.ErrUnknownField("Extra"),
+		},
+		"float": {
+			data: `{"Count":10}`,
+			v:    &map[string]any{},
+			want: &map[string]any{"Count": float64(10)},
+		},
+		"use number": {
+			serde: envelope.JsonSerde{UseNumber: true},
+			data:  `{"Count":10}`,
+			v:     &map[string]any{},
+			want:  &map[string]any{"Count": json.Number("10")},
+		},
+		"whitespace": {
+			data: " {\"Test\":\"testing\"}\n",
+			v:    &Test{},
+			want: &Test{Test: "testing"},
+		},
+		"trailing data": {
+			data:    `{"Test":"testing"} {}`,
+			v:       &Test{},
+			wantErr: errSyntax,
+		},
+		"strict trailing data": {
+			serde:   envelope.JsonSerde{DisallowUnknownFields: true},
+			data:    `{"Test":"testing"} {}`,
+			v:       &Test{},
+			wantErr: envelope.ErrTrailingData(2),
+		},
+		"empty": {
+			data:    ``,
+			v:       &Test{},
+			wantErr: errSyntax,
+		},
+		"strict empty": {
+			serde:   envelope.JsonSerde{DisallowUnknownFields: true},
+			data:    ` `,
+			v:       &Test{},
+			wantErr: io.ErrUnexpectedEOF,
+		},
+		"strict truncated": {
+			serde:   envelope.JsonSerde{UseNumber: true},
+			data:    `{"Test":`,
+			v:       &Test{},
+			wantErr: io.ErrUnexpectedEOF,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := tt.serde.Deserialize([]byte(tt.data), tt.v)
+			if errors.Is(err, io.EOF) {
+				t.Fatalf("JsonSerde.Deserialize() error = %v, which would end a stream", err)
+			}
+			var syntaxErr *json.SyntaxError
+			if tt.wantErr == errSyntax {
+				if !errors.As(err, &syntaxErr) {
+					t.Errorf("JsonSerde.Deserialize() error = %v, want %T", err, syntaxErr)
+				}
+				return
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("JsonSerde.Deserialize() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("JsonSerde.Deserialize() error = %v", err)
+			}
+			if !reflect.DeepEqual(tt.v, tt.want) {
+				t.Errorf("JsonSerde.Deserialize() = %v, want %v", tt.v, tt.want)
+			}
+		})
+	}
+}
+
+// driftedTest is a newer version of Test from a producer that has added a field
+type driftedTest struct {
+	Name  string `json:"-"`
+	Test  string
+	Extra string
+}
+
+func (t driftedTest) EnvelopeKey() string {
+	return t.Name
+}
+
+func TestRegistry_StrictJson(t *testing.T) {
+	producer := envelope.NewRegistry()
+	_ = producer.RegisterFactory(func() any { return &driftedTest{Name: "one"} })
+	_ = producer.RegisterFactory(func() any { return &driftedTest{Name: "two"} })
+	one, _ := producer.Serialize(&driftedTest{Name: "one", Test: "testing", Extra: "drift"})
+	two, _ := producer.Serialize(&driftedTest{Name: "two", Test: "testing", Extra: "drift"})
+
+	strict := envelope.JsonSerde{DisallowUnknownFields: true}
+
+	tests := map[string]struct {
+		registry func() envelope.Registry
+		data     []byte
+		wantErr  error
+	}{
+		"registry": {
+			registry: func() envelope.Registry {
+				r := envelope.NewRegistry(envelope.WithSerde(strict))
+				_ = r.RegisterFactory(func() any { return &Test{} })
+				_ = r.RegisterAlias("one", &Test{})
+				return r
+			},
+			data:    one.Bytes(),
+			wantErr: envelope.ErrUnknownField("Extra"),
+		},
+		"strict key": {
+			registry: func() envelope.Registry {
+				r := envelope.NewRegistry()
+				_ = r.RegisterWith(&Test{}, envelope.WithTypeSerde(strict))
+				_ = r.RegisterAlias("one", &Test{})
+				_ = r.RegisterWith(&KeyedTest{})
+				_ = r.RegisterAlias("two", &KeyedTest{})
+				return r
+			},
+			data:    one.Bytes(),
+			wantErr: envelope.ErrUnknownField("Extra"),
+		},
+		"lenient key": {
+			registry: func() envelope.Registry {
+				r := envelope.NewRegistry()
+				_ = r.RegisterWith(&Test{}, envelope.WithTypeSerde(strict))
+				_ = r.RegisterAlias("one", &Test{})
+				_ = r.RegisterWith(&KeyedTest{})
+				_ = r.RegisterAlias("two", &KeyedTest{})
+				return r
+			},
+			data: two.Bytes(),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := tt.registry().Deserialize(tt.data)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("Registry.Deserialize() error = %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Registry.Deserialize() error = %v, want %v", err, tt.wantErr)
+			}
+			var serdeErr *envelope.SerdeError
+			if !errors.As(err, &serdeErr) || serdeErr.Key != "envelope_test.Test" {
+				t.Errorf("Registry.Deserialize() error = %v, want it to name the key", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package envelope
+
+type (
+	SerializeOption func(*serializeConfig)
+
+	serializeConfig struct {
+		metadata map[string]string
+		subject  string
+	}
+)
+
+func newSerializeConfig(opts ...SerializeOption) *serializeConfig {
+	cfg := &serializeConfig{}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return cfg
+}
+
+// WithMetadata adds metadata to the envelope.
+//
+// The option may be used more than once; later values replace earlier ones with the same key.
+func WithMetadata(metadata map[string]string) SerializeOption {
+	return func(cfg *serializeConfig) {
+		if cfg.metadata == nil {
+			cfg.metadata = make(map[string]string, len(metadata))
+		}
+		for k, v := range metadata {
+			cfg.metadata[k] = v
+		}
+	}
+}
+
+// WithSubject encrypts the payload with the key of the subject.
+//
+// The registry must have been created with the WithSubjectKeys option.
+func WithSubject(subject string) SerializeOption {
+	return func(cfg *serializeConfig) {
+		cfg.subject = subject
+	}
+}
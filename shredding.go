@@ -0,0 +1,103 @@
+package envelope
+
+import (
+	"crypto/rand"
+	"sync"
+)
+
+type (
+	// SubjectKeyStore stores a data key for each subject, such as a user, whose
+	// payloads are encrypted with the WithSubject option.
+	//
+	// Deleting the key of a subject makes every payload encrypted for that subject
+	// undecryptable, which is also known as crypto-shredding.
+	SubjectKeyStore interface {
+		// KeyFor returns the key of the subject, creating a new key when the subject does not have one
+		KeyFor(subject string) ([]byte, error)
+		// Key returns the key of the subject, or false when the subject does not have a key
+		Key(subject string) ([]byte, bool, error)
+	}
+
+	// MemorySubjectKeyStore is an in-memory SubjectKeyStore
+	MemorySubjectKeyStore struct {
+		keys map[string][]byte
+		mu   sync.RWMutex
+	}
+)
+
+// NewMemorySubjectKeyStore creates a new in-memory SubjectKeyStore.
+func NewMemorySubjectKeyStore() *MemorySubjectKeyStore {
+	return &MemorySubjectKeyStore{
+		keys: make(map[string][]byte),
+	}
+}
+
+func (s *MemorySubjectKeyStore) KeyFor(subject string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key, exists := s.keys[subject]; exists {
+		return key, nil
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	s.keys[subject] = key
+	return key, nil
+}
+
+func (s *MemorySubjectKeyStore) Key(subject string) ([]byte, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, exists := s.keys[subject]
+	return key, exists, nil
+}
+
+// Forget deletes the key of the subject.
+func (s *MemorySubjectKeyStore) Forget(subject string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.keys, subject)
+}
+
+func (r *registry) encryptFor(key, subject string, data []byte) ([]byte, error) {
+	if subject == "" {
+		return data, nil
+	}
+
+	if r.subjectKeys == nil {
+		return nil, ErrNoKeyProvider(key)
+	}
+
+	secret, err := r.subjectKeys.KeyFor(subject)
+	if err != nil {
+		return nil, err
+	}
+
+	return seal(secret, data, []byte(key))
+}
+
+func (r *registry) decryptFor(key, subject string, data []byte) ([]byte, error) {
+	if subject == "" {
+		return data, nil
+	}
+
+	if r.subjectKeys == nil {
+		return nil, ErrNoKeyProvider(key)
+	}
+
+	secret, exists, err := r.subjectKeys.Key(subject)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrSubjectForgotten{Key: key, Subject: subject}
+	}
+
+	return open(secret, data, []byte(key))
+}
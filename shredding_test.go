@@ -0,0 +1,88 @@
+package envelope_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stackus/envelope"
+)
+
+func TestRegistry_Subject(t *testing.T) {
+	keys := envelope.NewMemoryKeyProvider()
+	_ = keys.AddKey("one", keyOne)
+
+	tests := map[string]struct {
+		options []envelope.RegistryOption
+		forget  []string
+		wantErr error
+	}{
+		"success": {},
+		"with encryption": {
+			options: []envelope.RegistryOption{envelope.WithEncryption(keys)},
+		},
+		"other subject forgotten": {
+			forget: []string{"user-2"},
+		},
+		"forgotten": {
+			forget: []string{"user-1"},
+			wantErr: envelope.ErrSubjectForgotten{
+				Key:     "envelope_test.Test",
+				Subject: "user-1",
+			},
+		},
+		"forgotten with encryption": {
+			options: []envelope.RegistryOption{envelope.WithEncryption(keys)},
+			forget:  []string{"user-1"},
+			wantErr: envelope.ErrSubjectForgotten{
+				Key:     "envelope_test.Test",
+				Subject: "user-1",
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			store := envelope.NewMemorySubjectKeyStore()
+			r := envelope.NewRegistry(append(tt.options, envelope.WithSubjectKeys(store))...)
+			_ = r.Register(&Test{})
+
+			env, err := r.Serialize(&Test{Test: "personal data"}, envelope.WithSubject("user-1"))
+			if err != nil {
+				t.Fatalf("Registry.Serialize() error = %v", err)
+			}
+			if bytes.Contains(env.Bytes(), []byte("personal data")) {
+				t.Errorf("Registry.Serialize() payload was not encrypted")
+			}
+			_, _ = r.Serialize(&Test{Test: "other data"}, envelope.WithSubject("user-2"))
+
+			for _, subject := range tt.forget {
+				store.Forget(subject)
+			}
+
+			received, err := r.Deserialize(env.Bytes())
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Registry.Deserialize() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Registry.Deserialize() error = %v", err)
+			}
+			if got := received.Payload().(*Test).Test; got != "personal data" {
+				t.Errorf("Registry.Deserialize() = %v, want %v", got, "personal data")
+			}
+		})
+	}
+}
+
+func TestRegistry_SubjectWithoutStore(t *testing.T) {
+	r := envelope.NewRegistry()
+	_ = r.Register(&Test{})
+
+	var noProvider envelope.ErrNoKeyProvider
+	if _, err := r.Serialize(&Test{}, envelope.WithSubject("user-1")); !errors.As(err, &noProvider) {
+		t.Errorf("Registry.Serialize() error = %v, want %T", err, noProvider)
+	}
+}
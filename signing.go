@@ -0,0 +1,155 @@
+package envelope
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"slices"
+)
+
+type (
+	// Signer signs envelopes and verifies their signatures.
+	Signer interface {
+		// Sign returns the ID of the signing key and the signature of the data
+		Sign(data []byte) (keyID string, signature []byte, err error)
+		// Verify returns an error when the signature is not valid for the data
+		Verify(keyID string, data, signature []byte) error
+	}
+
+	// HMACSigner is a Signer implementation for HMAC-SHA256
+	//
+	// Envelopes are signed with the key identified by KeyID. Any key in Keys may be
+	// used to verify a signature.
+	HMACSigner struct {
+		KeyID string
+		Keys  map[string][]byte
+	}
+
+	// Ed25519Signer is a Signer implementation for Ed25519
+	//
+	// Envelopes are signed with the PrivateKey, identified by KeyID. Signatures are verified
+	// using the public key from PublicKeys, or from the PrivateKey when the IDs match.
+	// A signer used only to verify envelopes does not need a PrivateKey.
+	Ed25519Signer struct {
+		KeyID      string
+		PrivateKey ed25519.PrivateKey
+		PublicKeys map[string]ed25519.PublicKey
+	}
+)
+
+func (s HMACSigner) Sign(data []byte) (string, []byte, error) {
+	key, exists := s.Keys[s.KeyID]
+	if !exists {
+		return "", nil, ErrKeyNotFound(s.KeyID)
+	}
+
+	return s.KeyID, hmacSum(key, data), nil
+}
+
+func (s HMACSigner) Verify(keyID string, data, signature []byte) error {
+	key, exists := s.Keys[keyID]
+	if !exists {
+		return ErrKeyNotFound(keyID)
+	}
+
+	if !hmac.Equal(hmacSum(key, data), signature) {
+		return ErrInvalidSignature(keyID)
+	}
+
+	return nil
+}
+
+func (s Ed25519Signer) Sign(data []byte) (string, []byte, error) {
+	if len(s.PrivateKey) != ed25519.PrivateKeySize {
+		return "", nil, ErrKeyNotFound(s.KeyID)
+	}
+
+	return s.KeyID, ed25519.Sign(s.PrivateKey, data), nil
+}
+
+func (s Ed25519Signer) Verify(keyID string, data, signature []byte) error {
+	key, exists := s.PublicKeys[keyID]
+	if !exists && keyID == s.KeyID && len(s.PrivateKey) == ed25519.PrivateKeySize {
+		key, exists = s.PrivateKey.Public().(ed25519.PublicKey), true
+	}
+	if !exists {
+		return ErrKeyNotFound(keyID)
+	}
+
+	if !ed25519.Verify(key, data, signature) {
+		return ErrInvalidSignature(keyID)
+	}
+
+	return nil
+}
+
+func (r *registry) sign(msg *EnvelopeMsg) error {
+	if r.signer == nil {
+		return nil
+	}
+
+	keyID, signature, err := r.signer.Sign(signingBytes(msg))
+	if err != nil {
+		return err
+	}
+
+	msg.Signature = signature
+	msg.SignatureKeyId = &keyID
+	return nil
+}
+
+func (r *registry) verify(msg *EnvelopeMsg) error {
+	if r.signer == nil {
+		return nil
+	}
+
+	if len(msg.GetSignature()) == 0 {
+		return ErrInvalidSignature(msg.GetKey())
+	}
+
+	if err := r.signer.Verify(msg.GetSignatureKeyId(), signingBytes(msg), msg.GetSignature()); err != nil {
+		return ErrInvalidSignature(msg.GetKey())
+	}
+
+	return nil
+}
+
+// signingBytes returns a stable encoding of everything in the envelope except the signature and its key ID
+func signingBytes(msg *EnvelopeMsg) []byte {
+	var data []byte
+
+	appendField := func(b []byte) {
+		data = binary.AppendUvarint(data, uint64(len(b)))
+		data = append(data, b...)
+	}
+
+	appendField([]byte(msg.GetKey()))
+	appendField(msg.GetPayload())
+
+	metadata := msg.GetMetadata()
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	data = binary.AppendUvarint(data, uint64(len(keys)))
+	for _, k := range keys {
+		appendField([]byte(k))
+		appendField([]byte(metadata[k]))
+	}
+
+	data = binary.AppendUvarint(data, uint64(msg.GetVersion()))
+	appendField([]byte(msg.GetContentType()))
+	appendField([]byte(msg.GetCompression()))
+	appendField([]byte(msg.GetKeyId()))
+	appendField([]byte(msg.GetSubject()))
+
+	return data
+}
+
+func hmacSum(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
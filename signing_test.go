@@ -0,0 +1,130 @@
+package envelope_test
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/stackus/envelope"
+)
+
+func TestRegistry_Signing(t *testing.T) {
+	hmacKeys := map[string][]byte{
+		"one": []byte("first secret"),
+		"two": []byte("second secret"),
+	}
+	_, privateKey, _ := ed25519.GenerateKey(nil)
+
+	tests := map[string]struct {
+		producer envelope.Signer
+		consumer envelope.Signer
+		tamper   func(msg *envelope.EnvelopeMsg)
+		wantErr  bool
+	}{
+		"hmac": {
+			producer: envelope.HMACSigner{KeyID: "one", Keys: hmacKeys},
+			consumer: envelope.HMACSigner{KeyID: "one", Keys: hmacKeys},
+		},
+		"hmac rotated": {
+			producer: envelope.HMACSigner{KeyID: "one", Keys: hmacKeys},
+			consumer: envelope.HMACSigner{KeyID: "two", Keys: hmacKeys},
+		},
+		"hmac unknown key": {
+			producer: envelope.HMACSigner{KeyID: "one", Keys: hmacKeys},
+			consumer: envelope.HMACSigner{KeyID: "two", Keys: map[string][]byte{"two": hmacKeys["two"]}},
+			wantErr:  true,
+		},
+		"ed25519": {
+			producer: envelope.Ed25519Signer{KeyID: "one", PrivateKey: privateKey},
+			consumer: envelope.Ed25519Signer{KeyID: "one", PrivateKey: privateKey},
+		},
+		"ed25519 public key": {
+			producer: envelope.Ed25519Signer{KeyID: "one", PrivateKey: privateKey},
+			consumer: envelope.Ed25519Signer{PublicKeys: map[string]ed25519.PublicKey{
+				"one": privateKey.Public().(ed25519.PublicKey),
+			}},
+		},
+		"unsigned": {
+			consumer: envelope.HMACSigner{KeyID: "one", Keys: hmacKeys},
+			wantErr:  true,
+		},
+		"tampered payload": {
+			producer: envelope.HMACSigner{KeyID: "one", Keys: hmacKeys},
+			consumer: envelope.HMACSigner{KeyID: "one", Keys: hmacKeys},
+			tamper: func(msg *envelope.EnvelopeMsg) {
+				msg.Payload = []byte(`{"Test":"tampered"}`)
+			},
+			wantErr: true,
+		},
+		"tampered key": {
+			producer: envelope.Ed25519Signer{KeyID: "one", PrivateKey: privateKey},
+			consumer: envelope.Ed25519Signer{KeyID: "one", PrivateKey: privateKey},
+			tamper: func(msg *envelope.EnvelopeMsg) {
+				msg.Key = proto.String("test")
+			},
+			wantErr: true,
+		},
+		"tampered metadata": {
+			producer: envelope.HMACSigner{KeyID: "one", Keys: hmacKeys},
+			consumer: envelope.HMACSigner{KeyID: "one", Keys: hmacKeys},
+			tamper: func(msg *envelope.EnvelopeMsg) {
+				msg.Metadata["tenant_id"] = "456"
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var producer envelope.Registry
+			if tt.producer != nil {
+				producer = envelope.NewRegistry(envelope.WithSigning(tt.producer))
+			} else {
+				producer = envelope.NewRegistry()
+			}
+			_ = producer.Register(&Test{})
+
+			var built int
+			consumer := envelope.NewRegistry(envelope.WithSigning(tt.consumer))
+			_ = consumer.RegisterFactory(func() any {
+				built++
+				return &Test{}
+			}, func() any {
+				built++
+				return &KeyedTest{}
+			})
+			built = 0
+
+			env, err := producer.Serialize(&Test{Test: "testing"}, envelope.WithMetadata(map[string]string{
+				"tenant_id": "123",
+			}))
+			if err != nil {
+				t.Fatalf("Registry.Serialize() error = %v", err)
+			}
+
+			data := env.Bytes()
+			if tt.tamper != nil {
+				msg := new(envelope.EnvelopeMsg)
+				_ = proto.Unmarshal(data, msg)
+				tt.tamper(msg)
+				data, _ = proto.Marshal(msg)
+			}
+
+			_, err = consumer.Deserialize(data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Registry.Deserialize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				var invalid envelope.ErrInvalidSignature
+				if !errors.As(err, &invalid) {
+					t.Errorf("Registry.Deserialize() error = %T, want %T", err, invalid)
+				}
+				if built != 0 {
+					t.Errorf("Registry.Deserialize() invoked the factory before verifying the signature")
+				}
+			}
+		})
+	}
+}
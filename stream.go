@@ -0,0 +1,120 @@
+package envelope
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+type (
+	// Encoder writes envelopes to a stream as length-delimited records
+	Encoder struct {
+		w      io.Writer
+		reg    Registry
+		offset int64
+	}
+
+	// Decoder reads length-delimited envelope records from a stream
+	Decoder struct {
+		r      *bufio.Reader
+		reg    Registry
+		offset int64
+		record int64
+	}
+)
+
+// NewEncoder creates a new Encoder that writes to w.
+//
+// Each record is the length of the envelope, encoded as an unsigned varint, followed
+// by the envelope bytes.
+func NewEncoder(w io.Writer, reg Registry) *Encoder {
+	return &Encoder{
+		w:   w,
+		reg: reg,
+	}
+}
+
+// Encode serializes a value using the registry and writes the envelope to the stream.
+func (e *Encoder) Encode(v any, opts ...SerializeOption) error {
+	env, err := e.reg.Serialize(v, opts...)
+	if err != nil {
+		return err
+	}
+
+	return e.EncodeEnvelope(env)
+}
+
+// EncodeEnvelope writes an envelope that has already been serialized to the stream.
+func (e *Encoder) EncodeEnvelope(env Envelope) error {
+	data := env.Bytes()
+	record := binary.AppendUvarint(make([]byte, 0, binary.MaxVarintLen64+len(data)), uint64(len(data)))
+	record = append(record, data...)
+
+	n, err := e.w.Write(record)
+	e.offset += int64(n)
+	return err
+}
+
+// Offset returns the number of bytes that have been written to the stream.
+func (e *Encoder) Offset() int64 {
+	return e.offset
+}
+
+// NewDecoder creates a new Decoder that reads from r.
+func NewDecoder(r io.Reader, reg Registry) *Decoder {
+	return &Decoder{
+		r:   bufio.NewReader(r),
+		reg: reg,
+	}
+}
+
+// Next reads the next record from the stream and deserializes it using the registry.
+//
+// An io.EOF error is returned once the end of the stream has been reached, and an
+// ErrTruncatedRecord error is returned when the stream ends partway through a record.
+// A record that cannot be deserialized is still consumed, allowing Next to be called
+// again to continue with the following record.
+func (d *Decoder) Next() (Envelope, error) {
+	d.record = d.offset
+
+	size, err := binary.ReadUvarint(byteCounter{d})
+	if err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, ErrTruncatedRecord(d.record)
+		}
+		return nil, err
+	}
+
+	// the buffer grows as the record is read so a corrupt length cannot allocate everything up front
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, d.r, int64(size))
+	d.offset += n
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrTruncatedRecord(d.record)
+		}
+		return nil, err
+	}
+
+	return d.reg.Deserialize(buf.Bytes())
+}
+
+// Offset returns the byte offset in the stream of the record last read by Next.
+func (d *Decoder) Offset() int64 {
+	return d.record
+}
+
+// byteCounter counts the bytes read by the decoder while reading the record length
+type byteCounter struct {
+	d *Decoder
+}
+
+func (c byteCounter) ReadByte() (byte, error) {
+	b, err := c.d.r.ReadByte()
+	if err == nil {
+		c.d.offset++
+	}
+	return b, err
+}
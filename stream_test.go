@@ -0,0 +1,143 @@
+package envelope_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stackus/envelope"
+)
+
+func TestEncoder_Decoder(t *testing.T) {
+	r := envelope.NewRegistry()
+	_ = r.Register(&Test{}, &KeyedTest{})
+
+	values := []any{&Test{Test: "one"}, &KeyedTest{Test: "two"}, &Test{Test: "three"}}
+
+	var buf bytes.Buffer
+	enc := envelope.NewEncoder(&buf, r)
+	var offsets []int64
+	for _, v := range values {
+		offsets = append(offsets, enc.Offset())
+		if err := enc.Encode(v); err != nil {
+			t.Fatalf("Encoder.Encode() error = %v", err)
+		}
+	}
+	if enc.Offset() != int64(buf.Len()) {
+		t.Errorf("Encoder.Offset() = %d, want %d", enc.Offset(), buf.Len())
+	}
+
+	dec := envelope.NewDecoder(bytes.NewReader(buf.Bytes()), r)
+	for i, v := range values {
+		env, err := dec.Next()
+		if err != nil {
+			t.Fatalf("Decoder.Next() error = %v", err)
+		}
+		if got, want := env.Payload().(TestType).String(), v.(TestType).String(); got != want {
+			t.Errorf("Decoder.Next() = %v, want %v", got, want)
+		}
+		if dec.Offset() != offsets[i] {
+			t.Errorf("Decoder.Offset() = %d, want %d", dec.Offset(), offsets[i])
+		}
+	}
+	if _, err := dec.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("Decoder.Next() error = %v, want %v", err, io.EOF)
+	}
+}
+
+func TestDecoder_Next(t *testing.T) {
+	producer := envelope.NewRegistry()
+	_ = producer.Register(&Test{}, &KeyedTest{})
+	consumer := envelope.NewRegistry()
+	_ = consumer.Register(&Test{})
+
+	var buf bytes.Buffer
+	enc := envelope.NewEncoder(&buf, producer)
+	_ = enc.Encode(&Test{Test: "one"})
+	_ = enc.Encode(&KeyedTest{Test: "two"})
+	last := enc.Offset()
+	_ = enc.Encode(&Test{Test: "three"})
+
+	tests := map[string]struct {
+		data     []byte
+		wantErrs []error
+	}{
+		"skips unregistered": {
+			data:     buf.Bytes(),
+			wantErrs: []error{nil, envelope.ErrUnregisteredKey("test"), nil, io.EOF},
+		},
+		"truncated record": {
+			data:     buf.Bytes()[:buf.Len()-3],
+			wantErrs: []error{nil, envelope.ErrUnregisteredKey("test"), envelope.ErrTruncatedRecord(last), io.EOF},
+		},
+		"truncated length": {
+			data:     append(buf.Bytes()[:last:last], 0x80),
+			wantErrs: []error{nil, envelope.ErrUnregisteredKey("test"), envelope.ErrTruncatedRecord(last), io.EOF},
+		},
+		"empty": {
+			data:     nil,
+			wantErrs: []error{io.EOF},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			dec := envelope.NewDecoder(bytes.NewReader(tt.data), consumer)
+			for i, wantErr := range tt.wantErrs {
+				if _, err := dec.Next(); !errors.Is(err, wantErr) {
+					t.Errorf("Decoder.Next() #%d error = %v, want %v", i, err, wantErr)
+				}
+			}
+		})
+	}
+}
+
+// emptySerde seals empty payloads
+type emptySerde struct{}
+
+func (emptySerde) Serialize(any) ([]byte, error) {
+	return []byte{}, nil
+}
+
+func (emptySerde) Deserialize([]byte, any) error {
+	return nil
+}
+
+func TestDecoder_NextEmptyPayload(t *testing.T) {
+	broken := envelope.NewRegistry(envelope.WithSerde(emptySerde{}))
+	_ = broken.Register(&Test{})
+	producer := envelope.NewRegistry()
+	_ = producer.Register(&Test{})
+
+	var buf bytes.Buffer
+	_ = envelope.NewEncoder(&buf, broken).Encode(&Test{Test: "one"})
+	_ = envelope.NewEncoder(&buf, producer).Encode(&Test{Test: "two"})
+
+	tests := map[string]envelope.Serde{
+		"lenient": envelope.JsonSerde{},
+		"strict":  envelope.JsonSerde{DisallowUnknownFields: true, UseNumber: true},
+	}
+
+	for name, serde := range tests {
+		t.Run(name, func(t *testing.T) {
+			consumer := envelope.NewRegistry(envelope.WithSerde(serde))
+			_ = consumer.Register(&Test{})
+			dec := envelope.NewDecoder(bytes.NewReader(buf.Bytes()), consumer)
+
+			if _, err := dec.Next(); err == nil || errors.Is(err, io.EOF) {
+				t.Fatalf("Decoder.Next() error = %v, want a non-EOF error", err)
+			}
+			env, err := dec.Next()
+			if err != nil {
+				t.Fatalf("Decoder.Next() error = %v", err)
+			}
+			if got := env.Payload().(*Test).Test; got != "two" {
+				t.Errorf("Decoder.Next() = %v, want %v", got, "two")
+			}
+			if _, err = dec.Next(); !errors.Is(err, io.EOF) {
+				t.Errorf("Decoder.Next() error = %v, want %v", err, io.EOF)
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package envelope
+
+type TypeOption func(*registration)
+
+// WithTypeSerde sets the Serde used for the payloads of a single registered type.
+//
+// Types registered without this option use the registry Serde.
+func WithTypeSerde(serde Serde) TypeOption {
+	return func(r *registration) {
+		r.serde = serde
+	}
+}
@@ -0,0 +1,148 @@
+package envelope
+
+import (
+	"reflect"
+)
+
+type (
+	// TypedEnvelope is an Envelope with a payload of a known type
+	TypedEnvelope[T any] interface {
+		Envelope
+		Value() T // Value returns the payload of the envelope as T
+	}
+
+	// TypedRegistry is a Registry that only accepts types that satisfy T
+	TypedRegistry[T any] struct {
+		Registry
+	}
+
+	typedEnvelope[T any] struct {
+		Envelope
+		value T
+	}
+)
+
+// Serialize serializes a value into an envelope using the registry.
+func Serialize[T any](r Registry, v T, opts ...SerializeOption) (TypedEnvelope[T], error) {
+	env, err := r.Serialize(v, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &typedEnvelope[T]{
+		Envelope: env,
+		value:    v,
+	}, nil
+}
+
+// Deserialize deserializes a byte slice into an envelope with a payload of type T.
+//
+// An ErrTypeMismatch error is returned when the deserialized payload does not satisfy T.
+func Deserialize[T any](r Registry, data []byte) (TypedEnvelope[T], error) {
+	env, err := r.Deserialize(data)
+	if err != nil {
+		return nil, err
+	}
+
+	value, ok := env.Payload().(T)
+	if !ok {
+		return nil, newTypeMismatch[T](env.Key())
+	}
+
+	return &typedEnvelope[T]{
+		Envelope: env,
+		value:    value,
+	}, nil
+}
+
+// DeserializeAs deserializes a byte slice and returns the payload as T.
+//
+// An ErrTypeMismatch error is returned when the deserialized payload does not satisfy T.
+func DeserializeAs[T any](r Registry, data []byte) (T, error) {
+	env, err := Deserialize[T](r, data)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return env.Value(), nil
+}
+
+// NewTypedRegistry creates a new envelope registry that only accepts types that satisfy T.
+//
+// Types are checked when they are registered. Deserialized payloads are always pointers,
+// so it is the pointer to each type that must satisfy T.
+func NewTypedRegistry[T any](opts ...RegistryOption) *TypedRegistry[T] {
+	return &TypedRegistry[T]{
+		Registry: NewRegistry(opts...),
+	}
+}
+
+// Register registers one or more types with the registry.
+//
+// An ErrTypeMismatch error is returned for the first type that does not satisfy T.
+func (r *TypedRegistry[T]) Register(vs ...any) error {
+	for _, v := range vs {
+		if err := r.check(v); err != nil {
+			return err
+		}
+	}
+
+	return r.Registry.Register(vs...)
+}
+
+// RegisterWith registers a type with the registry using the provided options.
+//
+// An ErrTypeMismatch error is returned when the type does not satisfy T.
+func (r *TypedRegistry[T]) RegisterWith(v any, opts ...TypeOption) error {
+	if err := r.check(v); err != nil {
+		return err
+	}
+
+	return r.Registry.RegisterWith(v, opts...)
+}
+
+// RegisterFactory registers one or more factory functions with the registry.
+//
+// An ErrTypeMismatch error is returned for the first factory that does not return a T.
+func (r *TypedRegistry[T]) RegisterFactory(fns ...func() any) error {
+	for _, fn := range fns {
+		v := fn()
+		if v == nil {
+			return ErrFactoryReturnsNil("")
+		}
+		if _, ok := v.(T); !ok {
+			return newTypeMismatch[T](getKey(v))
+		}
+	}
+
+	return r.Registry.RegisterFactory(fns...)
+}
+
+// DeserializeAs deserializes a byte slice and returns the payload as T.
+func (r *TypedRegistry[T]) DeserializeAs(data []byte) (T, error) {
+	return DeserializeAs[T](r.Registry, data)
+}
+
+func (r *TypedRegistry[T]) check(v any) error {
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if _, ok := reflect.New(t).Interface().(T); !ok {
+		return newTypeMismatch[T](getKey(v))
+	}
+
+	return nil
+}
+
+func (e *typedEnvelope[T]) Value() T {
+	return e.value
+}
+
+func newTypeMismatch[T any](key string) ErrTypeMismatch {
+	return ErrTypeMismatch{
+		Key:  key,
+		Type: reflect.TypeFor[T]().String(),
+	}
+}
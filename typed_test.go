@@ -0,0 +1,126 @@
+package envelope_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stackus/envelope"
+)
+
+func TestDeserializeAs(t *testing.T) {
+	r := envelope.NewRegistry()
+	_ = r.Register(&Test{}, &KeyedTest{})
+
+	env, err := envelope.Serialize(r, &Test{Test: "testing"})
+	if err != nil {
+		t.Fatalf("Serialize() error = %v", err)
+	}
+	if env.Value().Test != "testing" {
+		t.Errorf("Serialize() value = %v, want %v", env.Value().Test, "testing")
+	}
+
+	t.Run("interface", func(t *testing.T) {
+		got, err := envelope.DeserializeAs[TestType](r, env.Bytes())
+		if err != nil {
+			t.Fatalf("DeserializeAs() error = %v", err)
+		}
+		if got.String() != "testing" {
+			t.Errorf("DeserializeAs() = %v, want %v", got.String(), "testing")
+		}
+	})
+
+	t.Run("concrete", func(t *testing.T) {
+		got, err := envelope.Deserialize[*Test](r, env.Bytes())
+		if err != nil {
+			t.Fatalf("Deserialize() error = %v", err)
+		}
+		if got.Key() != "envelope_test.Test" {
+			t.Errorf("Deserialize() key = %v, want %v", got.Key(), "envelope_test.Test")
+		}
+		if got.Value().Test != "testing" {
+			t.Errorf("Deserialize() = %v, want %v", got.Value().Test, "testing")
+		}
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		var mismatch envelope.ErrTypeMismatch
+		if _, err := envelope.DeserializeAs[*KeyedTest](r, env.Bytes()); !errors.As(err, &mismatch) {
+			t.Errorf("DeserializeAs() error = %v, want %T", err, mismatch)
+		}
+	})
+
+	t.Run("not registered", func(t *testing.T) {
+		var unregistered envelope.ErrUnregisteredKey
+		if _, err := envelope.DeserializeAs[TestType](envelope.NewRegistry(), env.Bytes()); !errors.As(err, &unregistered) {
+			t.Errorf("DeserializeAs() error = %v, want %T", err, unregistered)
+		}
+	})
+}
+
+func TestTypedRegistry_Register(t *testing.T) {
+	tests := map[string]struct {
+		register func(r *envelope.TypedRegistry[TestType]) error
+		wantErr  bool
+	}{
+		"success": {
+			register: func(r *envelope.TypedRegistry[TestType]) error {
+				return r.Register(&Test{}, KeyedTest{})
+			},
+			wantErr: false,
+		},
+		"does not satisfy": {
+			register: func(r *envelope.TypedRegistry[TestType]) error {
+				return r.Register(&Test{}, &PrefixedTest{})
+			},
+			wantErr: true,
+		},
+		"factory success": {
+			register: func(r *envelope.TypedRegistry[TestType]) error {
+				return r.RegisterFactory(func() any {
+					return &Test{}
+				})
+			},
+			wantErr: false,
+		},
+		"factory does not satisfy": {
+			register: func(r *envelope.TypedRegistry[TestType]) error {
+				return r.RegisterFactory(func() any {
+					return &PrefixedTest{}
+				})
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := envelope.NewTypedRegistry[TestType]()
+			err := tt.register(r)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("TypedRegistry.Register() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var mismatch envelope.ErrTypeMismatch
+			if tt.wantErr && !errors.As(err, &mismatch) {
+				t.Errorf("TypedRegistry.Register() error = %T, want %T", err, mismatch)
+			}
+		})
+	}
+}
+
+func TestTypedRegistry_DeserializeAs(t *testing.T) {
+	r := envelope.NewTypedRegistry[TestType]()
+	_ = r.Register(&Test{})
+
+	env, err := r.Serialize(&Test{Test: "testing"})
+	if err != nil {
+		t.Fatalf("TypedRegistry.Serialize() error = %v", err)
+	}
+
+	got, err := r.DeserializeAs(env.Bytes())
+	if err != nil {
+		t.Fatalf("TypedRegistry.DeserializeAs() error = %v", err)
+	}
+	if got.String() != "testing" {
+		t.Errorf("TypedRegistry.DeserializeAs() = %v, want %v", got.String(), "testing")
+	}
+}
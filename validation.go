@@ -0,0 +1,46 @@
+package envelope
+
+import (
+	"reflect"
+)
+
+// Validator validates the payload of an envelope.
+//
+// A Validator may be provided to a registry with the WithValidator option to validate
+// every payload in addition to the Validate method of the payload itself.
+type Validator func(key string, v any) error
+
+// validate validates the payload with its Validate method and then the registry Validator
+func (r *registry) validate(key string, v any) error {
+	if validator, ok := getValidator(v); ok {
+		if err := validator.Validate(); err != nil {
+			return ErrValidation{Key: key, Err: err}
+		}
+	}
+
+	if r.validator != nil {
+		if err := r.validator(key, v); err != nil {
+			return ErrValidation{Key: key, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// getValidator returns the Validate method of the value, or of a pointer to a copy of the
+// value when Validate has a pointer receiver, so values are validated the same way whether
+// or not they are passed by pointer
+func getValidator(v any) (interface{ Validate() error }, bool) {
+	if validator, ok := v.(interface{ Validate() error }); ok {
+		return validator, true
+	}
+
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || rv.Kind() == reflect.Ptr {
+		return nil, false
+	}
+	ptr := reflect.New(rv.Type())
+	ptr.Elem().Set(rv)
+	validator, ok := ptr.Interface().(interface{ Validate() error })
+	return validator, ok
+}
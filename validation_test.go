@@ -0,0 +1,122 @@
+package envelope_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stackus/envelope"
+)
+
+var errMissingName = errors.New("name is required")
+
+type ValidatedTest struct {
+	Name string
+}
+
+func (t *ValidatedTest) Validate() error {
+	if t.Name == "" {
+		return errMissingName
+	}
+	return nil
+}
+
+// producedValidatedTest is a ValidatedTest from a producer that does not validate it
+type producedValidatedTest struct {
+	Name string
+}
+
+func (producedValidatedTest) EnvelopeKey() string {
+	return "envelope_test.ValidatedTest"
+}
+
+func TestRegistry_Validate(t *testing.T) {
+	errTooLong := errors.New("too long")
+	maxLength := func(_ string, v any) error {
+		if t, ok := v.(*Test); ok && len(t.Test) > 4 {
+			return errTooLong
+		}
+		return nil
+	}
+
+	tests := map[string]struct {
+		opts    []envelope.RegistryOption
+		v       any
+		wantKey string
+		wantErr error
+	}{
+		"valid": {
+			v: &ValidatedTest{Name: "testing"},
+		},
+		"valid value": {
+			v: ValidatedTest{Name: "testing"},
+		},
+		"invalid value": {
+			v:       ValidatedTest{},
+			wantKey: "envelope_test.ValidatedTest",
+			wantErr: errMissingName,
+		},
+		"invalid": {
+			v:       &ValidatedTest{},
+			wantKey: "envelope_test.ValidatedTest",
+			wantErr: errMissingName,
+		},
+		"no validate method": {
+			v: &Test{Test: "testing"},
+		},
+		"validator": {
+			opts: []envelope.RegistryOption{envelope.WithValidator(maxLength)},
+			v:    &Test{Test: "test"},
+		},
+		"invalid validator": {
+			opts:    []envelope.RegistryOption{envelope.WithValidator(maxLength)},
+			v:       &Test{Test: "testing"},
+			wantKey: "envelope_test.Test",
+			wantErr: errTooLong,
+		},
+		"validate method and validator": {
+			opts:    []envelope.RegistryOption{envelope.WithValidator(maxLength)},
+			v:       &ValidatedTest{},
+			wantKey: "envelope_test.ValidatedTest",
+			wantErr: errMissingName,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			producer := envelope.NewRegistry()
+			_ = producer.Register(&Test{}, &producedValidatedTest{})
+			consumer := envelope.NewRegistry(tt.opts...)
+			_ = consumer.Register(&Test{}, &ValidatedTest{})
+
+			produced := tt.v
+			switch v := tt.v.(type) {
+			case *ValidatedTest:
+				produced = &producedValidatedTest{Name: v.Name}
+			case ValidatedTest:
+				produced = &producedValidatedTest{Name: v.Name}
+			}
+			env, err := producer.Serialize(produced)
+			if err != nil {
+				t.Fatalf("Registry.Serialize() error = %v", err)
+			}
+
+			_, serializeErr := consumer.Serialize(tt.v)
+			_, deserializeErr := consumer.Deserialize(env.Bytes())
+			for op, err := range map[string]error{"Serialize": serializeErr, "Deserialize": deserializeErr} {
+				if tt.wantErr == nil {
+					if err != nil {
+						t.Errorf("Registry.%s() error = %v", op, err)
+					}
+					continue
+				}
+				var validationErr envelope.ErrValidation
+				if !errors.As(err, &validationErr) || validationErr.Key != tt.wantKey {
+					t.Errorf("Registry.%s() error = %v, want %T for %q", op, err, validationErr, tt.wantKey)
+				}
+				if !errors.Is(err, tt.wantErr) || !errors.Is(err, envelope.StageValidation) {
+					t.Errorf("Registry.%s() error = %v, want it to wrap %v", op, err, tt.wantErr)
+				}
+			}
+		})
+	}
+}
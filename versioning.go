@@ -0,0 +1,70 @@
+package envelope
+
+import (
+	"encoding/json"
+)
+
+// Upcaster transforms a serialized payload from one version of a type into the next version.
+type Upcaster func(data []byte) ([]byte, error)
+
+// JsonUpcaster creates an Upcaster for JSON payloads.
+//
+// The payload is decoded into a map which may be modified in place by fn before
+// it is encoded back into JSON. Numbers are decoded as a json.Number so that they
+// keep their precision. An ErrNotJSONObject error is returned for payloads that are
+// not JSON objects.
+func JsonUpcaster(fn func(map[string]any) error) Upcaster {
+	return func(data []byte) ([]byte, error) {
+		var v any
+		if err := (JsonSerde{UseNumber: true}).Deserialize(data, &v); err != nil {
+			return nil, err
+		}
+
+		// payloads come from outside the process and may be null or any other JSON value
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, ErrNotJSONObject{}
+		}
+
+		if err := fn(m); err != nil {
+			return nil, err
+		}
+
+		return json.Marshal(m)
+	}
+}
+
+func (r *registry) upcast(key string, from, to uint32, data []byte) ([]byte, error) {
+	// envelopes sealed before versions were recorded are the first version
+	if from == 0 {
+		from = 1
+	}
+
+	if from > to {
+		return nil, ErrUnsupportedVersion{Key: key, Version: from}
+	}
+
+	for version := from; version < to; version++ {
+		fn, exists := r.upcaster(key, version)
+		if !exists {
+			return nil, ErrMissingUpcaster{Key: key, Version: version}
+		}
+
+		var err error
+		if data, err = fn(data); err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+func getVersion(v any) uint32 {
+	if versioner, ok := v.(interface{ EnvelopeVersion() uint32 }); ok {
+		if version := versioner.EnvelopeVersion(); version > 0 {
+			return version
+		}
+	}
+
+	return 1
+}
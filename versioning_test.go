@@ -0,0 +1,253 @@
+package envelope_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/stackus/envelope"
+)
+
+type UserV1 struct {
+	Name string
+}
+
+type UserV2 struct {
+	FullName string
+}
+
+type UserV3 struct {
+	FirstName string
+	LastName  string
+}
+
+func (UserV1) EnvelopeKey() string { return "user" }
+
+func (UserV2) EnvelopeKey() string     { return "user" }
+func (UserV2) EnvelopeVersion() uint32 { return 2 }
+
+func (UserV3) EnvelopeKey() string     { return "user" }
+func (UserV3) EnvelopeVersion() uint32 { return 3 }
+
+var (
+	userV1ToV2 = envelope.JsonUpcaster(func(m map[string]any) error {
+		m["FullName"] = m["Name"]
+		delete(m, "Name")
+		return nil
+	})
+	userV2ToV3 = envelope.JsonUpcaster(func(m map[string]any) error {
+		first, last, _ := strings.Cut(m["FullName"].(string), " ")
+		m["FirstName"] = first
+		m["LastName"] = last
+		delete(m, "FullName")
+		return nil
+	})
+)
+
+func TestRegistry_Upcast(t *testing.T) {
+	tests := map[string]struct {
+		from    any
+		to      any
+		setup   func(r envelope.Registry)
+		want    UserV3
+		wantErr error
+	}{
+		"current version": {
+			from: &UserV3{FirstName: "John", LastName: "Doe"},
+			to:   &UserV3{},
+			want: UserV3{FirstName: "John", LastName: "Doe"},
+		},
+		"chained": {
+			from: &UserV1{Name: "John Doe"},
+			to:   &UserV3{},
+			setup: func(r envelope.Registry) {
+				_ = r.RegisterUpcaster("user", 1, userV1ToV2)
+				_ = r.RegisterUpcaster("user", 2, userV2ToV3)
+			},
+			want: UserV3{FirstName: "John", LastName: "Doe"},
+		},
+		"partial": {
+			from: &UserV2{FullName: "John Doe"},
+			to:   &UserV3{},
+			setup: func(r envelope.Registry) {
+				_ = r.RegisterUpcaster("user", 2, userV2ToV3)
+			},
+			want: UserV3{FirstName: "John", LastName: "Doe"},
+		},
+		"missing upcaster": {
+			from: &UserV1{Name: "John Doe"},
+			to:   &UserV3{},
+			setup: func(r envelope.Registry) {
+				_ = r.RegisterUpcaster("user", 1, userV1ToV2)
+			},
+			wantErr: envelope.ErrMissingUpcaster{Key: "user", Version: 2},
+		},
+		"newer version": {
+			from:    &UserV3{FirstName: "John", LastName: "Doe"},
+			to:      &UserV1{},
+			wantErr: envelope.ErrUnsupportedVersion{Key: "user", Version: 3},
+		},
+		"upcaster error": {
+			from: &UserV2{FullName: "John Doe"},
+			to:   &UserV3{},
+			setup: func(r envelope.Registry) {
+				_ = r.RegisterUpcaster("user", 2, func([]byte) ([]byte, error) {
+					return nil, errors.New("broken")
+				})
+			},
+			wantErr: errors.New("broken"),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			producer := envelope.NewRegistry()
+			_ = producer.Register(tt.from)
+			consumer := envelope.NewRegistry()
+			_ = consumer.Register(tt.to)
+			if tt.setup != nil {
+				tt.setup(consumer)
+			}
+
+			env, err := producer.Serialize(tt.from)
+			if err != nil {
+				t.Fatalf("Registry.Serialize() error = %v", err)
+			}
+
+			received, err := consumer.Deserialize(env.Bytes())
+			if tt.wantErr != nil {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr.Error()) {
+					t.Errorf("Registry.Deserialize() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Registry.Deserialize() error = %v", err)
+			}
+			if got := *received.Payload().(*UserV3); got != tt.want {
+				t.Errorf("Registry.Deserialize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistry_RegisterUpcaster(t *testing.T) {
+	r := envelope.NewRegistry()
+	if err := r.RegisterUpcaster("user", 1, userV1ToV2); err != nil {
+		t.Errorf("Registry.RegisterUpcaster() error = %v", err)
+	}
+
+	var reregistered envelope.ErrReregisteredUpcaster
+	if err := r.RegisterUpcaster("user", 1, userV1ToV2); !errors.As(err, &reregistered) {
+		t.Errorf("Registry.RegisterUpcaster() error = %v, want %T", err, reregistered)
+	}
+
+	r.Freeze()
+	var frozen envelope.ErrRegistryFrozen
+	if err := r.RegisterUpcaster("user", 2, userV2ToV3); !errors.As(err, &frozen) {
+		t.Errorf("Registry.RegisterUpcaster() error = %v, want %T", err, frozen)
+	}
+}
+
+func TestJsonUpcaster(t *testing.T) {
+	type payload struct {
+		ID    int64
+		Ratio float64
+		Name  string
+	}
+
+	tests := map[string]struct {
+		fn   func(map[string]any) error
+		want payload
+	}{
+		"no-op": {
+			fn:   func(map[string]any) error { return nil },
+			want: payload{ID: 9007199254740993, Ratio: 0.1},
+		},
+		"modified": {
+			fn: func(m map[string]any) error {
+				m["Name"] = "upcast"
+				return nil
+			},
+			want: payload{ID: 9007199254740993, Ratio: 0.1, Name: "upcast"},
+		},
+		"max int64": {
+			fn:   func(map[string]any) error { return nil },
+			want: payload{ID: math.MaxInt64, Ratio: 0.1},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			data, err := json.Marshal(payload{ID: tt.want.ID, Ratio: tt.want.Ratio})
+			if err != nil {
+				t.Fatalf("json.Marshal() error = %v", err)
+			}
+
+			data, err = envelope.JsonUpcaster(tt.fn)(data)
+			if err != nil {
+				t.Fatalf("JsonUpcaster() error = %v", err)
+			}
+
+			var got payload
+			if err = json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("JsonUpcaster() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJsonUpcaster_NotObject(t *testing.T) {
+	tests := map[string]struct {
+		data    string
+		wantErr error
+	}{
+		"null": {
+			data:    `null`,
+			wantErr: envelope.ErrNotJSONObject{},
+		},
+		"array": {
+			data:    `[{"Name":"John Doe"}]`,
+			wantErr: envelope.ErrNotJSONObject{},
+		},
+		"string": {
+			data:    `"John Doe"`,
+			wantErr: envelope.ErrNotJSONObject{},
+		},
+		"empty": {
+			data:    ``,
+			wantErr: io.ErrUnexpectedEOF,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := userV1ToV2([]byte(tt.data)); !errors.Is(err, tt.wantErr) {
+				t.Errorf("JsonUpcaster() error = %v, want %v", err, tt.wantErr)
+			}
+
+			r := envelope.NewRegistry()
+			_ = r.Register(&UserV2{})
+			_ = r.RegisterUpcaster("user", 1, userV1ToV2)
+			data, err := proto.Marshal(&envelope.EnvelopeMsg{
+				Key:     proto.String("user"),
+				Payload: []byte(tt.data),
+				Version: proto.Uint32(1),
+			})
+			if err != nil {
+				t.Fatalf("proto.Marshal() error = %v", err)
+			}
+			if _, err = r.Deserialize(data); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Registry.Deserialize() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}